@@ -5,11 +5,16 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/context"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/hook"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/inject"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/xlog"
+	"github.com/valyala/fasthttp"
+	"io"
 	"reflect"
-	"syscall"
 )
 
 // ---------------------------------------------------------------------------
@@ -26,24 +31,36 @@ var unusedError *error
 var unusedCtx *context.Ctx
 var typeOfError = reflect.TypeOf(unusedError).Elem()
 var typeOfCtx = reflect.TypeOf(unusedCtx).Elem()
+var typeOfFastHttpCtx = reflect.TypeOf(&fasthttp.RequestCtx{})
+var typeOfLogger = reflect.TypeOf(&xlog.Logger{})
+var typeOfBytes = reflect.TypeOf([]byte(nil))
+var typeOfReader = reflect.TypeOf((*io.Reader)(nil)).Elem()
+var typeOfPatch = reflect.TypeOf(param.Patch{})
 
-type contextType int
-type parametersType int
+var ErrMethodSignature = errors.New("invalid method signature")
+
+type paramSource int
 type returnValuesType int
 
 const (
-	noCtx contextType = iota
-	defaultCtx
-	customCtx
+	requestParam     paramSource = iota // bound from the request, see parseReqDefault
+	ctxParam                            // jet.Ctx or context.Ctx
+	fastHttpCtxParam                    // *fasthttp.RequestCtx
+	loggerParam                         // *xlog.Logger of the request context
+	injectParam                         // provided by inject.Provide
+	typedParam                          // typed wrapper like param.Query[T], see bindTyped
+	bodyParam                           // the raw body as []byte or io.Reader
 )
 
-const (
-	noParameter                  parametersType = iota
-	oneParameterAndFirstIsCtx                   // only one parameter and parameter are ctx
-	oneParameterAndFirstNotIsCtx                // only one parameter and parameter not are ctx
-	twoParameterAndFirstIsCtx                   // two parameters and the first parameter is ctx
-	twoParameterAndSecondIsCtx                  // two parameters and the second parameter are ctx
-)
+// methodParam describes how a single method parameter is resolved on each request
+type methodParam struct {
+	typ    reflect.Type
+	source paramSource
+	value  reflect.Value // resolved value of injectParam
+	name   string        // query or header name of typedParam
+	index  int           // path arg index of typedParam
+	body   bool          // requestParam bound from the body, the others are bound from the query string
+}
 
 const (
 	noReturnValue                  returnValuesType = iota
@@ -61,64 +78,35 @@ var handlerCreatorLog = xlog.NewWith("handle_create_log")
 //	(rcvr *XXXX) YYYY(ctx jet.Ctx, req ZZZZ) (err error)
 //	(rcvr *XXXX) YYYY(ctx jet.Ctx, req ZZZZ) (ret RRRR, err error)
 //	(rcvr *XXXX) YYYY(ctx jet.Ctx, req ZZZZ)
-//	(rcvr *XXXX) YYYY(ctx jet.Ctx, req ZZZZ) (ret RRRR, err error)
-//	(rcvr *XXXX) YYYY(ctx jet.Ctx, req ZZZZ) (err error)
+//	(rcvr *XXXX) YYYY(req ZZZZ, ctx jet.Ctx) (ret RRRR, err error)
 //	(rcvr *XXXX) YYYY(ctx jet.Ctx) (err error)
 //	(rcvr *XXXX) YYYY(ctx jet.Ctx) (ret RRRR, err error)
 //	(rcvr *XXXX) YYYY() (err error)
 //	(rcvr *XXXX) YYYY() (ret RRRR, err error)
+//	(rcvr *XXXX) YYYY(ctx jet.Ctx, path *PathArgs, body *CreateReq, q *Query, svc UserService) (ret RRRR, err error)
+//
+// Parameters may appear in any order. Besides jet.Ctx, Jet injects *fasthttp.RequestCtx,
+// *xlog.Logger and any type registered by inject.Provide, a []byte or io.Reader parameter is the raw body;
+// every other parameter is bound from the request: the first one but the path args from the body,
+// and the others from the query string.
 func (p HandlerCreator) New(rcvr *reflect.Value, method *reflect.Method) (IHandler, error) {
 	var (
 		mtype           = method.Type
-		methodNumIn     = mtype.NumIn() - 1
 		methodNumOut    = mtype.NumOut()
 		methodName      = method.Name
-		ctxType         = noCtx
-		parameterType   = noParameter
 		returnValueType = noReturnValue
+		params          []methodParam
+		err             error
 	)
-	// only allow up to two parameters
-	if methodNumIn > 2 {
-		handlerCreatorLog.Errorf("too many parameters, Method [%s] exceeds the default limit", methodName)
-		return nil, syscall.EINVAL
+	if params, err = parseMethodParams(method); err != nil {
+		handlerCreatorLog.Errorf("%v", err)
+		return nil, err
 	}
 	// only allow up to two return values allowed
 	if methodNumOut > 2 {
-		handlerCreatorLog.Errorf("too many return values, Method [%s] exceeds the default limit", methodName)
-		return nil, syscall.EINVAL
-	}
-	switch methodNumIn {
-	case 1:
-		in := mtype.In(1)
-		if in.Kind() == reflect.Ptr {
-			if in.Implements(typeOfCtx) {
-				ctxType++
-				parameterType = oneParameterAndFirstIsCtx
-			} else {
-				parameterType = oneParameterAndFirstNotIsCtx
-			}
-		} else if in.Kind() == reflect.Struct || in.Kind() == reflect.Interface {
-			if in == typeOfCtx || in.Implements(typeOfCtx) {
-				parameterType = oneParameterAndFirstIsCtx
-			}
-		} else {
-			parameterType = oneParameterAndFirstNotIsCtx
-		}
-	case 2:
-		firstIn, secondIn := mtype.In(1), mtype.In(2)
-		if firstIn.Kind() == reflect.Ptr {
-			firstIn = firstIn.Elem()
-		}
-		if secondIn.Kind() == reflect.Ptr {
-			secondIn = secondIn.Elem()
-		}
-		if firstIn.Implements(typeOfCtx) {
-			ctxType++
-			parameterType = twoParameterAndFirstIsCtx
-		} else if secondIn.Implements(typeOfCtx) {
-			ctxType++
-			parameterType = twoParameterAndSecondIsCtx
-		}
+		err = fmt.Errorf("%w: method [%s] has %d return values, at most two are allowed", ErrMethodSignature, methodName, methodNumOut)
+		handlerCreatorLog.Errorf("%v", err)
+		return nil, err
 	}
 	// NumOut
 	switch methodNumOut {
@@ -145,19 +133,143 @@ func (p HandlerCreator) New(rcvr *reflect.Value, method *reflect.Method) (IHandl
 			returnValueType = twoReturnValueAndFirstIsError
 		}
 		if secondOut.Kind() == reflect.Ptr {
-			if firstOut.Implements(typeOfError) {
+			if secondOut.Implements(typeOfError) {
 				returnValueType = twoReturnValueAndSecondIsError
 			}
 		} else if secondOut == typeOfError {
 			returnValueType = twoReturnValueAndSecondIsError
 		}
+		if returnValueType == noReturnValue {
+			err = fmt.Errorf("%w: method [%s] returns two values but neither is an error", ErrMethodSignature, methodName)
+			handlerCreatorLog.Errorf("%v", err)
+			return nil, err
+		}
 	}
 	return &handler{
 		rcvr:             rcvr,
 		method:           method,
-		ctxType:          ctxType,
-		parametersType:   parameterType,
+		params:           params,
+		config:           routeConfigOf(method.Func),
 		returnValuesType: returnValueType,
 		hook:             new(hook.Hook),
 	}, nil
 }
+
+// parseMethodParams classifies the parameters of a method, the receiver is skipped
+func parseMethodParams(method *reflect.Method) (params []methodParam, err error) {
+	var (
+		mtype      = method.Type
+		methodName = method.Name
+		seen       = make(map[paramSource]int)
+		names      = param.NamesOf(method.Func)
+		pathIndex  int
+	)
+	if mtype.IsVariadic() {
+		return nil, fmt.Errorf("%w: method [%s] is variadic", ErrMethodSignature, methodName)
+	}
+	for i := 1; i < mtype.NumIn(); i++ {
+		in := mtype.In(i)
+		p := methodParam{typ: in, source: paramSourceOf(in)}
+		switch p.source {
+		case ctxParam, fastHttpCtxParam, loggerParam:
+			if prev, ok := seen[p.source]; ok {
+				return nil, fmt.Errorf("%w: method [%s] parameter #%d (%v) duplicates parameter #%d",
+					ErrMethodSignature, methodName, i, in, prev)
+			}
+			seen[p.source] = i
+		case injectParam:
+			if p.value, err = inject.Resolve(in); err != nil {
+				return nil, fmt.Errorf("%w: method [%s] parameter #%d (%v) cannot be injected: %v",
+					ErrMethodSignature, methodName, i, in, err)
+			}
+		case typedParam:
+			switch typedSourceOf(in) {
+			case param.SourcePath:
+				p.index = pathIndex
+				pathIndex++
+			case param.SourceQuery, param.SourceHeader:
+				if len(names) == 0 || names[0] == "" {
+					return nil, fmt.Errorf("%w: method [%s] parameter #%d (%v) has no name, register it by param.Names",
+						ErrMethodSignature, methodName, i, in)
+				}
+				p.name, names = names[0], names[1:]
+			}
+		case requestParam:
+			if err = checkRequestParam(in); err != nil {
+				return nil, fmt.Errorf("%w: method [%s] parameter #%d (%v) %v",
+					ErrMethodSignature, methodName, i, in, err)
+			}
+			if err = setDefaults(reflect.New(indirectType(in))); err != nil {
+				return nil, fmt.Errorf("%w: method [%s] parameter #%d (%v) has an invalid default: %v",
+					ErrMethodSignature, methodName, i, in, err)
+			}
+		}
+		params = append(params, p)
+	}
+	markBodyParam(params)
+	return
+}
+
+// markBodyParam marks the request parameter bound from the body, the param.Patch one if any, else the first one
+// but the path args, like body in (ctx jet.Ctx, path *PathArgs, body *CreateReq, q *Query).
+// None is marked if a param.Body[T] or a raw []byte or io.Reader parameter takes the body.
+func markBodyParam(params []methodParam) {
+	for _, p := range params {
+		if p.source == bodyParam || p.source == typedParam && typedSourceOf(p.typ) == param.SourceBody {
+			return
+		}
+	}
+	for i, p := range params {
+		if p.source == requestParam && indirectType(p.typ) == typeOfPatch {
+			params[i].body = true
+			return
+		}
+	}
+	for i, p := range params {
+		if p.source == requestParam && !isPathArgs(p.typ) {
+			params[i].body = true
+			return
+		}
+	}
+}
+
+// isPathArgs reports whether the type is a struct of the CmdArgs field only, which takes the path args
+func isPathArgs(in reflect.Type) bool {
+	in = indirectType(in)
+	if in.Kind() != reflect.Struct || in.NumField() != 1 {
+		return false
+	}
+	_, ok := in.FieldByName("CmdArgs")
+	return ok
+}
+
+func paramSourceOf(in reflect.Type) paramSource {
+	switch {
+	case in == typeOfFastHttpCtx:
+		return fastHttpCtxParam
+	case in == typeOfLogger:
+		return loggerParam
+	case in == typeOfCtx || in.Implements(typeOfCtx):
+		return ctxParam
+	case isTypedParam(in):
+		return typedParam
+	case in == typeOfBytes || in == typeOfReader:
+		return bodyParam
+	case inject.IsProvided(in):
+		return injectParam
+	}
+	return requestParam
+}
+
+func checkRequestParam(in reflect.Type) error {
+	if in.Kind() == reflect.Ptr {
+		in = in.Elem()
+	}
+	switch in.Kind() {
+	case reflect.Interface:
+		return errors.New("is an interface that was not registered by inject.Provide")
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return errors.New("cannot be bound from the request")
+	}
+	return nil
+}
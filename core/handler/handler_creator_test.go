@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/context"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/inject"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	jeterrors "github.com/fengyuan-liang/jet-web-fasthttp/pkg/errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type userService interface {
+	Name() string
+}
+
+type defaultUserService struct{}
+
+func (defaultUserService) Name() string { return "jet" }
+
+func init() {
+	inject.Provide(func() userService { return defaultUserService{} })
+}
+
+type pathArgs struct {
+	CmdArgs []string
+}
+
+type createReq struct {
+	Name string `json:"name" form:"name"`
+}
+
+type creatorController struct{}
+
+func (c *creatorController) PostUser(ctx context.Ctx, path *pathArgs, body *createReq, svc userService, log *xlog.Logger, fctx *fasthttp.RequestCtx) (string, error) {
+	return svc.Name(), nil
+}
+
+func (c *creatorController) PostDuplicateCtx(ctx context.Ctx, ctx0 context.Ctx) error {
+	return nil
+}
+
+func (c *creatorController) PostUnknownService(svc interface{ Unknown() }) error {
+	return nil
+}
+
+func (c *creatorController) PostTwoValues() (string, string) {
+	return "", ""
+}
+
+func TestHandlerCreator_New(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	typ := rcvr.Type()
+
+	testData := []struct {
+		method string
+		ok     bool
+	}{
+		{"PostUser", true},
+		{"PostDuplicateCtx", false},
+		{"PostUnknownService", false},
+		{"PostTwoValues", false},
+	}
+	for _, td := range testData {
+		method, _ := typ.MethodByName(td.method)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		if td.ok {
+			assert.NoError(t, err, td.method)
+			assert.NotNil(t, h, td.method)
+		} else {
+			assert.True(t, errors.Is(err, ErrMethodSignature), "For method '%s', got '%v'", td.method, err)
+		}
+	}
+
+	method, _ := typ.MethodByName("PostUser")
+	params, err := parseMethodParams(&method)
+	assert.NoError(t, err)
+	sources := make([]paramSource, 0, len(params))
+	for _, p := range params {
+		sources = append(sources, p.source)
+	}
+	assert.Equal(t, []paramSource{ctxParam, requestParam, requestParam, injectParam, loggerParam, fastHttpCtxParam}, sources)
+}
+
+func TestHandler_ServeHTTPWithInjectedParams(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	method, _ := rcvr.Type().MethodByName("PostUser")
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+
+	ctx := newRequestCtx(fasthttp.MethodPost, "/user", "application/json", `{"name":"jet"}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, "jet", string(ctx.Response.Body()))
+}
+
+type listQuery struct {
+	Page int    `json:"page" form:"page"`
+	Name string `json:"name" form:"name"`
+}
+
+func (c *creatorController) PostSearch(path *pathArgs, body *createReq, q *listQuery) (error, map[string]any) {
+	return nil, map[string]any{"args": path.CmdArgs, "body": body.Name, "page": q.Page, "query_name": q.Name}
+}
+
+func TestHandler_ServeHTTPBodyAndQuery(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	method, _ := rcvr.Type().MethodByName("PostSearch")
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+
+	// only the first request parameter but the path args is bound from the body
+	ctx := newRequestCtx(fasthttp.MethodPost, "/search/7?page=2&name=query", "application/json", `{"name":"body","page":9}`)
+	h.ServeHTTP(ctx, []string{"7"})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"args":["7"],"body":"body","page":2,"query_name":"query"}`, string(ctx.Response.Body()))
+}
+
+func (c *creatorController) PostTypedBody(b param.Body[createReq], q *listQuery) (error, map[string]any) {
+	return nil, map[string]any{"body": b.Get().Name, "page": q.Page}
+}
+
+func (c *creatorController) PostRawBody(b []byte, q *listQuery) (error, map[string]any) {
+	return nil, map[string]any{"body": string(b), "page": q.Page}
+}
+
+func TestHandler_ServeHTTPBodyParamAndQuery(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	// a param.Body or a raw body parameter takes the body, the request parameters are bound from the query string
+	for name, want := range map[string]string{
+		"PostTypedBody": `{"body":"body","page":2}`,
+		"PostRawBody":   `{"body":"{\"name\":\"body\",\"page\":9}","page":2}`,
+	} {
+		method, _ := rcvr.Type().MethodByName(name)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		assert.NoError(t, err)
+		ctx := newRequestCtx(fasthttp.MethodPost, "/search?page=2", "application/json", `{"name":"body","page":9}`)
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), name)
+		assert.JSONEq(t, want, string(ctx.Response.Body()), name)
+	}
+
+	method, _ := rcvr.Type().MethodByName("PostRawBody")
+	h, _ := HandlerCreator{}.New(&rcvr, &method)
+	ctx := newRequestCtx(fasthttp.MethodPost, "/search?page=3", "text/plain", "plain text")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"body":"plain text","page":3}`, string(ctx.Response.Body()))
+}
+
+func (c *creatorController) GetList(q *listQuery) (error, *listQuery) {
+	return nil, q
+}
+
+func TestHandler_ServeHTTPBadInput(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	for name, ctx := range map[string]*fasthttp.RequestCtx{
+		"GetList":    newRequestCtx(fasthttp.MethodGet, "/list?page=abc", "", ""),
+		"PostSearch": newRequestCtx(fasthttp.MethodPost, "/search?page=2", "application/json", `{"name":`),
+	} {
+		method, _ := rcvr.Type().MethodByName(name)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		assert.NoError(t, err)
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode(), name)
+	}
+}
+
+func newRequestCtx(method, uri, contentType, body string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	if contentType != "" {
+		ctx.Request.Header.SetContentType(contentType)
+	}
+	if body != "" {
+		ctx.Request.SetBodyString(body)
+		ctx.Request.Header.SetContentLength(len(body))
+	}
+	return ctx
+}
+
+type defaultsReq struct {
+	Page    int      `json:"page" form:"page" default:"1"`
+	Size    int      `json:"size" form:"size" default:"20"`
+	Sort    []string `json:"sort" form:"sort" default:"id,name"`
+	Enabled bool     `json:"enabled" form:"enabled" default:"true"`
+}
+
+func (c *creatorController) PostDefaults(req *defaultsReq) (error, *defaultsReq) {
+	return nil, req
+}
+
+func TestHandler_ServeHTTPWithDefaults(t *testing.T) {
+	rcvr := reflect.ValueOf(&creatorController{})
+	method, _ := rcvr.Type().MethodByName("PostDefaults")
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+
+	ctx := newRequestCtx(fasthttp.MethodPost, "/defaults?size=5", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"page":1,"size":5,"sort":["id","name"],"enabled":true}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodPost, "/defaults", "application/json", `{"page":3,"enabled":false}`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"page":3,"size":20,"sort":["id","name"],"enabled":false}`, string(ctx.Response.Body()))
+}
+
+type shapeUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+type shapeController struct{}
+
+func (c *shapeController) GetValueError() (*shapeUser, error) { return &shapeUser{"张三", 18}, nil }
+func (c *shapeController) GetErrorValue() (error, shapeUser)  { return nil, shapeUser{"张三", 18} }
+func (c *shapeController) GetValue() shapeUser                { return shapeUser{"张三", 18} }
+func (c *shapeController) GetString() (string, error)         { return "张三", nil }
+func (c *shapeController) GetBytes() []byte                   { return []byte{0xff, 0x00} }
+func (c *shapeController) GetNil() (*shapeUser, error)        { return nil, nil }
+func (c *shapeController) GetJSONString() string              { return `{"name":"张三"}` }
+func (c *shapeController) GetJSONBytes() []byte               { return []byte(`[1]`) }
+func (c *shapeController) GetRawJSON() json.RawMessage        { return json.RawMessage(`{"name":"张三"}`) }
+func (c *shapeController) GetError() (*shapeUser, error)      { return nil, errors.New("boom") }
+
+func TestHandler_ServeHTTPReturnShapes(t *testing.T) {
+	rcvr := reflect.ValueOf(&shapeController{})
+	for name, want := range map[string][2]string{
+		"GetValueError": {`{"name":"张三","age":18}`, "application/json"},
+		"GetErrorValue": {`{"name":"张三","age":18}`, "application/json"},
+		"GetValue":      {`{"name":"张三","age":18}`, "application/json"},
+		"GetString":     {"张三", "text/plain; charset=utf-8"},
+		"GetBytes":      {"\xff\x00", "application/octet-stream"},
+		"GetNil":        {"", "text/plain; charset=utf-8"},
+		"GetJSONString": {`{"name":"张三"}`, "text/plain; charset=utf-8"},
+		"GetJSONBytes":  {"[1]", "application/octet-stream"},
+		"GetRawJSON":    {`{"name":"张三"}`, "application/json"},
+		"GetError":      {"boom", ""},
+	} {
+		method, _ := rcvr.Type().MethodByName(name)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		assert.NoError(t, err)
+		ctx := newRequestCtx(fasthttp.MethodGet, "/", "", "")
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, want[0], string(ctx.Response.Body()), name)
+		if want[1] != "" {
+			assert.Equal(t, want[1], string(ctx.Response.Header.ContentType()), name)
+		}
+	}
+}
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "short and stout" }
+func (teapotError) StatusCode() int { return fasthttp.StatusTeapot }
+
+type errorController struct{}
+
+func (c *errorController) GetNotFound() error {
+	return constant.NewError(constant.StatusNotFound, "user not found").WithReason("USER_NOT_FOUND").WithDetails(map[string]any{"id": 7})
+}
+func (c *errorController) GetWrapped() (*shapeUser, error) {
+	return nil, fmt.Errorf("load user: %w", constant.ErrForbidden)
+}
+func (c *errorController) GetErrorInfo() error {
+	return jeterrors.Info(constant.ErrConflict, "save user").Detail(errors.New("duplicate key"))
+}
+func (c *errorController) GetTeapot() error { return teapotError{} }
+func (c *errorController) GetPlain() error  { return errors.New("boom") }
+func (c *errorController) GetUnauthorized(ctx context.Ctx) error {
+	// the status set on the response is kept for a plain error
+	ctx.Response().SetStatusCode(fasthttp.StatusUnauthorized)
+	return errors.New("bad token")
+}
+
+func TestHandler_ServeHTTPTypedErrors(t *testing.T) {
+	rcvr := reflect.ValueOf(&errorController{})
+	for name, want := range map[string]struct {
+		status int
+		body   string
+	}{
+		"GetNotFound":     {fasthttp.StatusNotFound, `{"code":404,"message":"user not found","reason":"USER_NOT_FOUND","details":{"id":7}}`},
+		"GetWrapped":      {fasthttp.StatusForbidden, `{"code":403,"message":"Forbidden"}`},
+		"GetErrorInfo":    {fasthttp.StatusConflict, `{"code":409,"message":"Conflict"}`},
+		"GetTeapot":       {fasthttp.StatusTeapot, "short and stout"},
+		"GetPlain":        {fasthttp.StatusInternalServerError, "boom"},
+		"GetUnauthorized": {fasthttp.StatusUnauthorized, "bad token"},
+	} {
+		method, _ := rcvr.Type().MethodByName(name)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		assert.NoError(t, err)
+		ctx := newRequestCtx(fasthttp.MethodGet, "/", "", "")
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, want.status, ctx.Response.StatusCode(), name)
+		assert.Equal(t, want.body, string(ctx.Response.Body()), name)
+	}
+
+	err := constant.ErrNotFound.WithReason("X").Wrap(sql.ErrNoRows)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Empty(t, constant.ErrNotFound.Reason, "the shared errors are not modified")
+}
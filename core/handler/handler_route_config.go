@@ -0,0 +1,67 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"sync"
+)
+
+// RouteConfig is the per-route configuration of a handler method
+type RouteConfig struct {
+	// StrictJSON rejects unknown fields, duplicate keys and trailing data of a json body with 400
+	StrictJSON bool
+	// MaxBodySize rejects a larger request body with 413, 0 means no limit besides the server one.
+	// It is checked once fasthttp has read the whole body, so it limits what the route accepts, not the memory
+	// a request takes: fasthttp.Server.MaxRequestBodySize, 4 MB by default, is the cap on what is buffered,
+	// set it by jet.SetFastHttpServer to at least the largest MaxBodySize
+	MaxBodySize int
+	// MaxJSONDepth rejects a json body nested deeper with 400, 0 means no limit
+	MaxJSONDepth int
+	// RawResponse skips the envelope set by SetEnvelope, like file downloads and health checks
+	RawResponse bool
+	// DisableETag skips the weak ETag hashing the body of a GET or HEAD response, like responses changing
+	// on every request, the ETag of an ETagger or of the method is still sent
+	DisableETag bool
+}
+
+var (
+	defaultRouteConfig = RouteConfig{}
+	routeConfigs       = make(map[uintptr]RouteConfig)
+	routeConfigsLock   sync.RWMutex
+)
+
+// SetDefaultRouteConfig sets the RouteConfig of the methods that are not configured by ConfigureRoute
+func SetDefaultRouteConfig(config RouteConfig) {
+	routeConfigsLock.Lock()
+	defer routeConfigsLock.Unlock()
+	defaultRouteConfig = config
+}
+
+// ConfigureRoute sets the RouteConfig of a handler method, like
+//
+//	handler.ConfigureRoute((*Controller).PostV1User, handler.RouteConfig{StrictJSON: true, MaxBodySize: 1 << 20})
+//
+// method must be a method expression of the pointer receiver, and be configured before the controller is registered.
+func ConfigureRoute(method any, config RouteConfig) {
+	v := reflect.ValueOf(method)
+	if v.Kind() != reflect.Func {
+		panic("handler.ConfigureRoute: method is not a func")
+	}
+	routeConfigsLock.Lock()
+	defer routeConfigsLock.Unlock()
+	routeConfigs[v.Pointer()] = config
+}
+
+// routeConfigOf returns the RouteConfig of the method func
+func routeConfigOf(method reflect.Value) *RouteConfig {
+	routeConfigsLock.RLock()
+	defer routeConfigsLock.RUnlock()
+	config, ok := routeConfigs[method.Pointer()]
+	if !ok {
+		config = defaultRouteConfig
+	}
+	return &config
+}
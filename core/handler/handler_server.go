@@ -5,9 +5,11 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/context"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/hook"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/xlog"
@@ -18,8 +20,8 @@ import (
 type handler struct {
 	rcvr             *reflect.Value
 	method           *reflect.Method
-	ctxType          contextType
-	parametersType   parametersType
+	params           []methodParam
+	config           *RouteConfig
 	returnValuesType returnValuesType
 	hook             *hook.Hook
 }
@@ -28,10 +30,12 @@ var handlerLog = xlog.NewWith("handler_log")
 
 func (h handler) ServeHTTP(ctx *fasthttp.RequestCtx, args []string) {
 	switch string(ctx.Method()) {
-	case constant.MethodGet, constant.MethodPost, constant.MethodPut, constant.MethodDelete:
+	case constant.MethodGet, constant.MethodPost, constant.MethodPut, constant.MethodPatch, constant.MethodDelete:
 		h.handleRequest(ctx, args)
 	case constant.MethodHead:
-
+		// answered like GET, the body is kept for the Content-Length and the ETag but not sent
+		ctx.Response.SkipBody = true
+		h.handleRequest(ctx, args)
 	}
 }
 
@@ -44,14 +48,15 @@ func (h handler) AddHook(hooks *hook.Hook) {
 func (h handler) handleRequest(ctx *fasthttp.RequestCtx, args []string) {
 	var (
 		uri         = ctx.URI().String()
-		mtype       = h.method.Type
-		methodArgs  = []reflect.Value{*h.rcvr}
+		methodArgs  = make([]reflect.Value, 0, len(h.params)+1)
 		param       reflect.Value
 		err         error
 		jetCtx      = context.NewContext(ctx)
 		jetCtxValue = reflect.ValueOf(jetCtx)
 	)
 	handlerLog.Debugf("handle uri[%s]", uri)
+	ctx.SetUserValue(reqIdKey, jetCtx.Logger().ReqId)
+	methodArgs = append(methodArgs, *h.rcvr)
 
 	// global hook
 	if len(hook.PostJetCtxInitHooks) > 0 {
@@ -60,121 +65,128 @@ func (h handler) handleRequest(ctx *fasthttp.RequestCtx, args []string) {
 		}
 	}
 
+	// the body is already read, fasthttp.Server.MaxRequestBodySize caps what is buffered
+	if h.config.MaxBodySize > 0 && len(ctx.Request.Body()) > h.config.MaxBodySize {
+		h.fail(ctx, constant.NewError(constant.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body exceeds %d bytes", h.config.MaxBodySize)))
+		return
+	}
+
 	// handle PreMethodExecuteHook
 	if h.hook.HasPreMethodExecuteHooks() {
 		if err = h.hook.PreMethodExecuteHook(jetCtxValue); err != nil {
-			FailHandler(ctx, err.Error())
+			h.fail(ctx, err)
 			return
 		}
 	}
 
-	switch h.parametersType {
-	case noParameter:
-		// noting to do
-	case oneParameterAndFirstIsCtx:
-		methodArgs = append(methodArgs, jetCtxValue)
-	case oneParameterAndFirstNotIsCtx:
-		param, err = h.handleParam(ctx, args, mtype.In(1), err)
-		if err != nil {
-			handlerLog.Errorf("handler err: %v", err.Error())
-			FailHandler(ctx, err.Error())
-			return
-		}
-		// handle postParamsParseHook
-		if err = h.hook.PostParamsParse(param); err != nil {
-			FailHandler(ctx, err.Error())
-			return
-		}
-		methodArgs = append(methodArgs, param)
-	case twoParameterAndFirstIsCtx:
-		// handle param
-		param, err = h.handleParam(ctx, args, mtype.In(2), err)
-		if err != nil {
-			handlerLog.Errorf("handler err: %v", err.Error())
-			FailHandler(ctx, err.Error())
-			return
+	for _, p := range h.params {
+		switch p.source {
+		case ctxParam:
+			methodArgs = append(methodArgs, jetCtxValue)
+		case fastHttpCtxParam:
+			methodArgs = append(methodArgs, reflect.ValueOf(ctx))
+		case loggerParam:
+			methodArgs = append(methodArgs, reflect.ValueOf(jetCtx.Logger()))
+		case injectParam:
+			methodArgs = append(methodArgs, p.value)
+		case bodyParam:
+			methodArgs = append(methodArgs, rawBodyOf(ctx, p.typ))
+		case requestParam, typedParam:
+			// handle param
+			if p.source == typedParam {
+				param, err = h.handleTypedParam(ctx, args, p)
+			} else {
+				param, err = h.handleParam(ctx, args, p)
+			}
+			if err != nil {
+				handlerLog.Errorf("handler err: %v", err.Error())
+				h.fail(ctx, err)
+				return
+			}
+			// handle postParamsParseHook
+			if err = h.hook.PostParamsParse(param); err != nil {
+				h.fail(ctx, err)
+				return
+			}
+			methodArgs = append(methodArgs, param)
+		default:
+			panic("illegal method signature")
 		}
-		// handle postParamsParseHook
-		if err = h.hook.PostParamsParse(param); err != nil {
-			FailHandler(ctx, err.Error())
-			return
+	}
+
+	callValues := h.method.Func.Call(methodArgs)
+
+	data, err := h.resultOf(callValues)
+	if err != nil {
+		h.fail(ctx, err)
+		return
+	}
+	if h.returnValuesType == noReturnValue {
+		return
+	}
+	responder, _ := data.(Responder)
+	if responder != nil {
+		if data = responder.ResponseBody(); data != nil && isNilValue(reflect.ValueOf(data)) {
+			data = nil
 		}
-		methodArgs = append(methodArgs, jetCtxValue, param)
-	case twoParameterAndSecondIsCtx:
-		// handle param
-		param, err = h.handleParam(ctx, args, mtype.In(1), err)
-		if err != nil {
-			handlerLog.Errorf("handler err: %v", err.Error())
-			FailHandler(ctx, err.Error())
+	}
+	_, raw := data.(ResponseWriter)
+	// handle PostMethodExecuteHook
+	if data != nil && !raw && h.hook.HasPostMethodExecuteHook() {
+		if data, err = h.hook.PostMethodExecuteHook(reflect.ValueOf(data)); err != nil {
+			h.fail(ctx, err)
 			return
 		}
-		// handle postParamsParseHook
-		if err = h.hook.PostParamsParse(param); err != nil {
-			FailHandler(ctx, err.Error())
-			return
+	}
+	if responder == nil {
+		h.write(ctx, data)
+	} else {
+		if data != nil {
+			h.write(ctx, data)
 		}
-		methodArgs = append(methodArgs, param, jetCtxValue)
-	default:
-		panic("illegal method signature")
+		// the status, headers and cookies of the Responder take precedence
+		responder.ApplyResponse(&ctx.Response)
 	}
+	h.conditional(ctx, data)
+}
 
-	callValues := h.method.Func.Call(methodArgs)
-
+// resultOf splits the return values of the method into the data and the error,
+// a nil pointer or interface is nil
+func (h handler) resultOf(callValues []reflect.Value) (data any, err error) {
+	var dataValue, errValue reflect.Value
 	switch h.returnValuesType {
-	case noReturnValue:
-		// noting to do
 	case OneReturnValueAndIsError:
-		if callValues[0].Interface() != nil {
-			err = callValues[0].Interface().(error)
-			FailHandler(ctx, err.Error())
-		} else {
-			SuccessHandler(ctx, constant.EmptyString)
-		}
+		errValue = callValues[0]
 	case OneReturnValueAndNotError:
-		if callValues[0].Interface() != nil {
-			data := callValues[0].Interface()
-			SuccessHandler(ctx, fmt.Sprintf("%v", data))
-		} else {
-			SuccessHandler(ctx, constant.EmptyString)
-		}
+		dataValue = callValues[0]
 	case twoReturnValueAndFirstIsError:
-		if callValues[0].Interface() != nil {
-			err = callValues[0].Interface().(error)
-			FailHandler(ctx, err.Error())
-			return
-		}
-		if callValues[1].Interface() != nil {
-			data := callValues[1].Interface()
-			RestSuccessHandler(ctx, data)
-		} else {
-			SuccessHandler(ctx, constant.EmptyString)
-		}
+		errValue, dataValue = callValues[0], callValues[1]
 	case twoReturnValueAndSecondIsError:
-		if callValues[1].Interface() != nil {
-			err = callValues[1].Interface().(error)
-			FailHandler(ctx, err.Error())
-			return
-		}
-		if callValues[0].Interface() != nil {
-			// handle postParamsParseHook
-			postData := callValues[0].Interface()
-			if h.hook.HasPostMethodExecuteHook() {
-				if postData, err = h.hook.PostMethodExecuteHook(callValues[0]); err != nil {
-					FailHandler(ctx, err.Error())
-					return
-				}
-			}
-			SuccessHandler(ctx, fmt.Sprintf("%v", postData))
-		} else {
-			SuccessHandler(ctx, constant.EmptyString)
-		}
+		dataValue, errValue = callValues[0], callValues[1]
+	}
+	if errValue.IsValid() && !isNilValue(errValue) {
+		return nil, errValue.Interface().(error)
+	}
+	if dataValue.IsValid() && !isNilValue(dataValue) {
+		data = dataValue.Interface()
 	}
 	return
 }
 
-func (h handler) handleParam(ctx *fasthttp.RequestCtx, args []string, in reflect.Type, err error) (reflect.Value, error) {
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (h handler) handleParam(ctx *fasthttp.RequestCtx, args []string, p methodParam) (reflect.Value, error) {
 	var (
+		in         = p.typ
 		paramIsPtr bool
+		err        error
 	)
 	if in.Kind() == reflect.Ptr {
 		in = in.Elem()
@@ -182,34 +194,98 @@ func (h handler) handleParam(ctx *fasthttp.RequestCtx, args []string, in reflect
 	}
 	// the value is ptr
 	value := reflect.New(in)
-	if err = parseReqDefault(ctx, value, args); err != nil {
-		xlog.Errorf("parseReqDefault err: %v", err.Error())
+	if err = setDefaults(value); err != nil {
 		return reflect.Value{}, err
 	}
+	if p.body {
+		err = parseReqDefault(ctx, value, args, h.config)
+	} else {
+		err = parseReqQuery(ctx, value, args)
+	}
+	if err != nil {
+		xlog.Errorf("parseReqDefault err: %v", err.Error())
+		return reflect.Value{}, badRequest(err)
+	}
+	if err = bindTypedFields(ctx, value, args, h.config); err != nil {
+		return reflect.Value{}, badRequest(err)
+	}
 	if !paramIsPtr {
 		value = value.Elem()
 	}
 	return value, err
 }
 
-func parseReqDefault(ctx *fasthttp.RequestCtx, param reflect.Value, args []string) (err error) {
-	// query path
-	if len(args) > 0 {
+func (h handler) handleTypedParam(ctx *fasthttp.RequestCtx, args []string, p methodParam) (reflect.Value, error) {
+	var (
+		in         = p.typ
+		paramIsPtr bool
+	)
+	if in.Kind() == reflect.Ptr {
+		in = in.Elem()
+		paramIsPtr = true
+	}
+	value := reflect.New(in)
+	if err := bindTyped(ctx, value.Interface().(param.Typed), p.name, p.index, args, "", h.config); err != nil {
+		return reflect.Value{}, badRequest(err)
+	}
+	if !paramIsPtr {
+		value = value.Elem()
+	}
+	return value, nil
+}
+
+// badRequest returns a failure to bind or decode the request as 400, unless it carries a status code of its own,
+// like the 415 of an unsupported media type
+func badRequest(err error) error {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return err
+	}
+	return constant.NewError(constant.StatusBadRequest, err.Error()).Wrap(err)
+}
+
+// setCmdArgs sets the path args into the CmdArgs field, done is true if it is the only field
+func setCmdArgs(param reflect.Value, args []string) (done bool) {
+	if len(args) > 0 && param.Elem().Kind() == reflect.Struct {
 		v := param.Elem().FieldByName("CmdArgs")
 		if v.IsValid() {
 			v.Set(reflect.ValueOf(args))
-			if param.Elem().NumField() == 1 {
-				return
-			}
+			return param.Elem().NumField() == 1
 		}
 	}
-	if isJsonCall(&ctx.Request) {
+	return false
+}
+
+// parseReqQuery binds a request parameter besides the body one from the path args and the query string
+func parseReqQuery(ctx *fasthttp.RequestCtx, param reflect.Value, args []string) error {
+	if setCmdArgs(param, args) {
+		return nil
+	}
+	return parseValue(param, ctx, "form")
+}
+
+func parseReqDefault(ctx *fasthttp.RequestCtx, param reflect.Value, args []string, config *RouteConfig) (err error) {
+	// query path
+	if setCmdArgs(param, args) {
+		return
+	}
+	if ok, patchErr := bindPatch(ctx, param); ok {
+		return patchErr
+	}
+	if isJsonCall(&ctx.Request) || isMergePatchCall(&ctx.Request) {
 		if ctx.Request.Header.ContentLength() <= 0 || len(ctx.Request.Body()) <= 0 {
 			return parseValue(param, ctx, "form")
 		}
+		if config.StrictJSON || config.MaxJSONDepth > 0 {
+			if err = checkJSON(ctx.Request.Body(), param.Type(), config); err != nil {
+				return
+			}
+		}
 		return utils.ByteToObj(ctx.Request.Body(), param.Interface())
 	} else if isFormCall(&ctx.Request) {
 		return parseForm(param, ctx)
+	} else if len(ctx.Request.Body()) > 0 && mediaTypeOf(&ctx.Request) != "" {
+		return decodeBody(&ctx.Request, param)
 	} else {
 		return parseValue(param, ctx, "form")
 	}
@@ -5,6 +5,8 @@
 package handler
 
 import (
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/errors"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
@@ -31,7 +33,7 @@ func parseReqWithBody(ret *reflect.Value, ctx *fasthttp.RequestCtx) error {
 		}
 		return utils.Decode(req.BodyStream(), ret.Interface())
 	} else if isFormCall(req) {
-		return parseForm(retElem, ctx)
+		return parseForm(*ret, ctx)
 	}
 	return syscall.EINVAL
 }
@@ -41,108 +43,11 @@ func parseValue(v reflect.Value, ctx *fasthttp.RequestCtx, cate string) (err err
 		err = errors.Info(syscall.EINVAL, "formutil.ParseValue: ret.type != pointer")
 		return
 	}
-	v = v.Elem()
-	t := v.Type()
-	if v.Kind() != reflect.Struct {
-		switch v.Kind() {
-		case reflect.Slice: // uri like ?a=1&a=1&a=3 => function(a []int) => a [1, 2, 3]
-			peeks := ctx.QueryArgs().PeekMulti(t.Name())
-			if peeks == nil {
-				v.Set(reflect.Zero(t))
-				return
-			}
-			multiPeek := parseMultiPeek(peeks)
-			// slice element type
-			sliceType := t.Elem()
-			slice := reflect.MakeSlice(sliceType, 0, 0)
-			for index, peek := range multiPeek {
-				err = strconvParseValue(slice.Index(index), peek)
-				if err != nil {
-					err = errors.Info(err, "formutil.ParseValue: parse slice field -", t.Name(), index).Detail(err)
-					return
-				}
-			}
-			v.Set(slice)
-		case reflect.Map:
-			if ctx.QueryArgs().Len() == 0 {
-				v.Set(reflect.Zero(t))
-				return
-			}
-			m := make(map[string]any)
-			ctx.QueryArgs().VisitAll(func(key, value []byte) {
-				m[string(key)] = string(value)
-			})
-			v.Set(reflect.ValueOf(m))
-		default: // uri like ?a=1 => function(a int) => a 1
-			// just handle one args
-			var arg string
-			ctx.URI().QueryArgs().VisitAll(func(key, value []byte) {
-				arg = string(value)
-			})
-			err = strconvParseValue(v, arg)
-		}
+	root := new(valueNode)
+	if err = root.addArgs(ctx.QueryArgs()); err != nil {
 		return
 	}
-	// args is struct
-	for i := 0; i < v.NumField(); i++ {
-		sf := t.Field(i)
-		if sf.Tag == "" { // no tag
-			if sf.Anonymous {
-				if err = parseValue(v.Field(i).Addr(), ctx, cate); err != nil {
-					return
-				}
-			}
-			continue
-		}
-		formTag := sf.Tag.Get(cate)
-		if formTag == "" { // no form tag, skip
-			continue
-		}
-		tag, opts, parseTagErr := parseTag(formTag)
-		if parseTagErr != nil {
-			err = errors.Info(parseTagErr, "Parse struct field:", sf.Name).Detail(parseTagErr)
-			return
-		}
-		sfv := v.Field(i)
-		peeks := ctx.QueryArgs().PeekMulti(tag)
-		if opts.fhas {
-			if err = setHas(v, sf.Name, peeks == nil); err != nil {
-				return
-			}
-		}
-		if peeks == nil {
-			if !opts.fdefault {
-				sfv.Set(reflect.Zero(sf.Type))
-			}
-			continue
-		}
-		fv := parseMultiPeek(peeks)
-		if len(fv) == 0 {
-			sfv.Set(reflect.Zero(sf.Type))
-			continue
-		}
-		switch sfv.Kind() {
-		case reflect.Slice:
-			sft := sfv.Type()
-			n := len(fv)
-			slice := reflect.MakeSlice(sft, n, n)
-			for j := 0; j < n; j++ {
-				err = strconvParseValue(slice.Index(j), fv[j])
-				if err != nil {
-					err = errors.Info(err, "formutil.ParseValue: parse slice field -", sf.Name, j).Detail(err)
-					return
-				}
-			}
-			sfv.Set(slice)
-		default:
-			err = strconvParseValue(sfv, fv[0])
-			if err != nil {
-				err = errors.Info(err, "formutil.ParseValue: parse struct field -", sf.Name).Detail(err)
-				return
-			}
-		}
-	}
-	return
+	return bindValues(v.Elem(), root, cate)
 }
 
 func parseMultiPeek(peeks [][]byte) []string {
@@ -165,6 +70,44 @@ func isJsonCall(req *fasthttp.Request) bool {
 	return ct == "application/json" || strings.HasPrefix(ct, "application/json;")
 }
 
+// patchKindOf returns the param.PatchKind of a merge patch or json patch call, 0 otherwise
+func patchKindOf(req *fasthttp.Request) param.PatchKind {
+	ct := string(req.Header.Peek(constant.HeaderContentType))
+	for _, kind := range []param.PatchKind{param.MergePatch, param.JSONPatch} {
+		if mime := kind.String(); ct == mime || strings.HasPrefix(ct, mime+";") {
+			return kind
+		}
+	}
+	return 0
+}
+
+// isMergePatchCall reports whether the body is a merge patch, which is bound like a json body
+func isMergePatchCall(req *fasthttp.Request) bool {
+	return patchKindOf(req) == param.MergePatch
+}
+
+// bindPatch binds the body of a merge patch or json patch call into a *param.Patch,
+// ok is false if v is neither a *param.Patch nor the body parameter of a json patch call,
+// which is rejected as the method has no param.Patch parameter, see markBodyParam.
+func bindPatch(ctx *fasthttp.RequestCtx, v reflect.Value) (ok bool, err error) {
+	kind := patchKindOf(&ctx.Request)
+	patch, isPatch := v.Interface().(*param.Patch)
+	if !isPatch {
+		if kind == param.JSONPatch {
+			return true, constant.NewError(constant.StatusUnsupportedMediaType,
+				"json patch: the body must be bound into param.Patch")
+		}
+		return false, nil
+	}
+	if kind == 0 {
+		return true, constant.NewError(constant.StatusUnsupportedMediaType,
+			fmt.Sprintf("patch: Content-Type must be %s or %s", param.MergePatch, param.JSONPatch))
+	}
+	patch.Kind = kind
+	patch.Document = append(patch.Document[:0], ctx.Request.Body()...)
+	return true, nil
+}
+
 func isFormCall(req *fasthttp.Request) bool {
 	var ct string
 	if ctBytes := req.Header.Peek(constant.HeaderContentType); ctBytes == nil {
@@ -175,29 +118,25 @@ func isFormCall(req *fasthttp.Request) bool {
 	return ct == "application/x-www-form-urlencoded" || strings.HasPrefix(ct, "application/x-www-form-urlencoded;") || ct == "multipart/form-data" || strings.HasPrefix(ct, "multipart/form-data;")
 }
 
+// parseForm binds the urlencoded or multipart form body, together with the query string,
+// exactly like parseValue does for the query string.
 func parseForm(retElem reflect.Value, ctx *fasthttp.RequestCtx) (err error) {
 	if retElem.Kind() != reflect.Ptr {
 		err = errors.Info(syscall.EINVAL, "formutil.ParseValue: ret.type != pointer")
 		return
 	}
-	v := retElem.Elem()
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		sf := t.Field(i)
-		formTag := sf.Tag.Get("form")
-		if formTag == "" {
-			continue
-		}
-		sfv := v.Field(i)
-		formValue := ctx.FormValue(formTag)
-		if len(formValue) == 0 {
-			continue
-		}
-		if err = strconvParseValue(sfv, string(formValue)); err != nil {
-			return errors.Info(err, "formutil.ParseValue: parse form field -", sf.Name).Detail(err)
+	root := new(valueNode)
+	if err = root.addArgs(ctx.QueryArgs()); err != nil {
+		return
+	}
+	if mf, mfErr := ctx.MultipartForm(); mfErr == nil {
+		if err = root.addMultiValues(mf.Value); err != nil {
+			return
 		}
+	} else if err = root.addArgs(ctx.PostArgs()); err != nil {
+		return
 	}
-	return nil
+	return bindValues(retElem.Elem(), root, "form")
 }
 
 func prefixOf(name string) (prefix string, ok bool) {
@@ -270,12 +209,20 @@ func strconvParse(ret interface{}, str string) (err error) {
 }
 
 func strconvParseValue(v reflect.Value, str string) (err error) {
+	return strconvParseValueFormat(v, str, "")
+}
+
+// strconvParseValueFormat is strconvParseValue with the layout of time.Time, see the `time_format` tag
+func strconvParseValueFormat(v reflect.Value, str string, timeFormat string) (err error) {
 
 	var iv int64
 	var uv uint64
 	var fv float64
 
 retry:
+	if ok, convertErr := convertValue(v, str, timeFormat); ok {
+		return convertErr
+	}
 	switch v.Kind() {
 	case reflect.String:
 		v.SetString(str)
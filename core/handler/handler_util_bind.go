@@ -0,0 +1,442 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/errors"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArrayFormat is how a slice is encoded in the query string or form body
+type ArrayFormat int
+
+const (
+	// ArrayFormatRepeat accepts ids=1&ids=2, ids[]=1&ids[]=2 and ids[0]=1&ids[1]=2
+	ArrayFormatRepeat ArrayFormat = iota
+	// ArrayFormatComma accepts ids=1,2,3 in addition to ArrayFormatRepeat
+	ArrayFormatComma
+)
+
+// BindConfig controls how nested keys like filter[status]=active or page.size=10 are bound
+type BindConfig struct {
+	ArrayFormat ArrayFormat
+	MaxDepth    int // max segments of a key, filter[status] has 2
+	MaxElements int // max length of a slice, also the max index like ids[999], and max entries of a map
+}
+
+var bindConfig = BindConfig{
+	ArrayFormat: ArrayFormatRepeat,
+	MaxDepth:    8,
+	MaxElements: 1000,
+}
+
+// SetBindConfig replaces the global BindConfig, zero limits keep the defaults.
+// It must be called before the server starts, every request reads the config without synchronization.
+func SetBindConfig(config BindConfig) {
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = bindConfig.MaxDepth
+	}
+	if config.MaxElements <= 0 {
+		config.MaxElements = bindConfig.MaxElements
+	}
+	bindConfig = config
+}
+
+// ---------------------------------------------------------------------------
+
+// valueNode is a tree of the flattened keys, a[b][c]=1 and a.b.c=1 are both stored as a -> b -> c
+type valueNode struct {
+	values   []string
+	children map[string]*valueNode
+	lastKey  string // the last key added to the root, see bindValues
+}
+
+func (n *valueNode) child(segment string) *valueNode {
+	if n.children == nil {
+		n.children = make(map[string]*valueNode)
+	}
+	c, ok := n.children[segment]
+	if !ok {
+		c = new(valueNode)
+		n.children[segment] = c
+	}
+	return c
+}
+
+// lookup finds the node of a key like "page.size", nil if absent
+func (n *valueNode) lookup(key string) *valueNode {
+	for _, segment := range splitKey(key) {
+		if n == nil || n.children == nil {
+			return nil
+		}
+		n = n.children[segment]
+	}
+	return n
+}
+
+// addArgs adds the query string or urlencoded form args
+func (n *valueNode) addArgs(args *fasthttp.Args) (err error) {
+	args.VisitAll(func(key, value []byte) {
+		if err == nil {
+			err = n.add(string(key), string(value))
+		}
+	})
+	return
+}
+
+// addMultiValues adds the values of a multipart form
+func (n *valueNode) addMultiValues(values map[string][]string) (err error) {
+	for key, vs := range values {
+		for _, value := range vs {
+			if err = n.add(key, value); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (n *valueNode) add(key string, value string) error {
+	segments := splitKey(key)
+	if len(segments) > bindConfig.MaxDepth {
+		return fmt.Errorf("key [%s] is nested deeper than %d", key, bindConfig.MaxDepth)
+	}
+	n.lastKey = key
+	for _, segment := range segments {
+		n = n.child(segment)
+	}
+	n.values = append(n.values, value)
+	return nil
+}
+
+// splitKey splits filter[status] and filter.status into [filter status], ids[] into [ids ""]
+func splitKey(key string) (segments []string) {
+	var start int
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.':
+			if i > start {
+				segments = append(segments, key[start:i])
+			}
+			start = i + 1
+		case '[':
+			if i > start {
+				segments = append(segments, key[start:i])
+			}
+			end := strings.IndexByte(key[i:], ']')
+			if end == -1 {
+				return append(segments, key[i:])
+			}
+			segments = append(segments, key[i+1:i+end])
+			i += end
+			start = i + 1
+		}
+	}
+	if start < len(key) || len(segments) == 0 {
+		segments = append(segments, key[start:])
+	}
+	return
+}
+
+// ---------------------------------------------------------------------------
+
+// bindValues binds v from the tree of the query string or form body
+func bindValues(v reflect.Value, root *valueNode, cate string) (err error) {
+	switch v.Kind() {
+	case reflect.Struct: // uri like ?name=jet&page.size=10 => function(req *Req)
+		return bindStruct(v, root, cate)
+	case reflect.Map: // uri like ?a=1&filter[status]=active => function(m map[string]any)
+		if len(root.children) == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		return bindMap(v, root, cate, "")
+	}
+	// there is no name of a parameter, so just take the last key,
+	// uri like ?a=1 => function(a int) => a 1, ?a=1&a=2 => function(a []int) => a [1, 2]
+	last := root.lookup(root.lastKey)
+	if last == nil || len(last.values) == 0 {
+		return
+	}
+	if isMultiValue(v.Type()) {
+		err = bindSlice(v, last, cate, "")
+	} else {
+		err = strconvParseValue(v, last.values[len(last.values)-1])
+	}
+	if err != nil {
+		err = errors.Info(err, "formutil.ParseValue: parse", root.lastKey).Detail(err)
+	}
+	return
+}
+
+// bindStruct binds the fields of the struct v from node, the field name is the first part of the tag named cate
+func bindStruct(v reflect.Value, node *valueNode, cate string) (err error) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		if isTypedParam(sf.Type) { // see bindTypedFields
+			continue
+		}
+		if sf.Tag == "" { // no tag
+			if sf.Anonymous {
+				sfv := v.Field(i)
+				if sfv.Kind() == reflect.Ptr && sfv.Type().Elem().Kind() == reflect.Struct {
+					if sfv.IsNil() {
+						sfv.Set(reflect.New(sfv.Type().Elem()))
+					}
+					sfv = sfv.Elem()
+				}
+				if sfv.Kind() == reflect.Struct {
+					if err = bindStruct(sfv, node, cate); err != nil {
+						return
+					}
+				}
+			}
+			continue
+		}
+		formTag := sf.Tag.Get(cate)
+		if formTag == "" { // no form tag, skip
+			continue
+		}
+		tag, opts, parseTagErr := parseTag(formTag)
+		if parseTagErr != nil {
+			err = errors.Info(parseTagErr, "Parse struct field:", sf.Name).Detail(parseTagErr)
+			return
+		}
+		sfv := v.Field(i)
+		child := node.lookup(tag)
+		if opts.fhas {
+			if err = setHas(v, sf.Name, child != nil); err != nil {
+				return
+			}
+		}
+		if child == nil {
+			if !opts.fdefault && !hasDefault(sf) {
+				sfv.Set(reflect.Zero(sf.Type))
+			}
+			continue
+		}
+		if err = bindNode(sfv, child, cate, sf.Tag.Get("time_format")); err != nil {
+			err = errors.Info(err, "formutil.ParseValue: parse struct field -", sf.Name).Detail(err)
+			return
+		}
+	}
+	return
+}
+
+// bindNode binds v from node, nested structs, maps and slices walk the children of node
+func bindNode(v reflect.Value, node *valueNode, cate string, timeFormat string) (err error) {
+	t := v.Type()
+	if opt, ok := optionalOf(v); ok {
+		target := opt.OptionalTarget()
+		if len(node.children) == 0 && len(node.values) > 0 && node.values[0] == "" && target.Kind() != reflect.String {
+			opt.MarkPresent(true)
+			return nil
+		}
+		opt.MarkPresent(false)
+		return bindNode(target, node, cate, timeFormat)
+	}
+	if t.Kind() == reflect.Ptr && isNestedType(t.Elem()) {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return bindNode(v.Elem(), node, cate, timeFormat)
+	}
+	switch {
+	case t.Kind() == reflect.Struct && isNestedType(t):
+		return bindStruct(v, node, cate)
+	case t.Kind() == reflect.Map:
+		return bindMap(v, node, cate, timeFormat)
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		v.Set(reflect.ValueOf(nodeToAny(node)))
+		return nil
+	case isMultiValue(t):
+		return bindSlice(v, node, cate, timeFormat)
+	}
+	if len(node.values) == 0 {
+		return nil
+	}
+	return strconvParseValueFormat(v, node.values[0], timeFormat)
+}
+
+func bindSlice(v reflect.Value, node *valueNode, cate string, timeFormat string) (err error) {
+	var elems []*valueNode
+	for _, value := range node.values {
+		if bindConfig.ArrayFormat == ArrayFormatComma {
+			for _, part := range strings.Split(value, ",") {
+				elems = append(elems, &valueNode{values: []string{part}})
+			}
+			continue
+		}
+		elems = append(elems, &valueNode{values: []string{value}})
+	}
+	if appended, ok := node.children[""]; ok { // ids[]=1&ids[]=2
+		for _, value := range appended.values {
+			elems = append(elems, &valueNode{values: []string{value}})
+		}
+	}
+	type indexed struct {
+		index int
+		node  *valueNode
+	}
+	var indexes []indexed
+	for key, child := range node.children { // ids[0]=1&ids[1]=2 or users[0][name]=jet
+		if key == "" {
+			continue
+		}
+		index, convErr := strconv.Atoi(key)
+		if convErr != nil || index < 0 {
+			return fmt.Errorf("invalid slice index [%s]", key)
+		}
+		if index >= bindConfig.MaxElements {
+			return fmt.Errorf("slice index [%d] exceeds the limit %d", index, bindConfig.MaxElements)
+		}
+		indexes = append(indexes, indexed{index, child})
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].index < indexes[j].index })
+	for _, elem := range indexes {
+		elems = append(elems, elem.node)
+	}
+	if len(elems) > bindConfig.MaxElements {
+		return fmt.Errorf("slice has %d elements, exceeds the limit %d", len(elems), bindConfig.MaxElements)
+	}
+	slice := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+	for i, elem := range elems {
+		if err = bindNode(slice.Index(i), elem, cate, timeFormat); err != nil {
+			return
+		}
+	}
+	v.Set(slice)
+	return
+}
+
+func bindMap(v reflect.Value, node *valueNode, cate string, timeFormat string) (err error) {
+	t := v.Type()
+	if len(node.children) > bindConfig.MaxElements {
+		return fmt.Errorf("map has %d entries, exceeds the limit %d", len(node.children), bindConfig.MaxElements)
+	}
+	m := reflect.MakeMapWithSize(t, len(node.children))
+	for key, child := range node.children {
+		mk := reflect.New(t.Key()).Elem()
+		if err = strconvParseValue(mk, key); err != nil {
+			return fmt.Errorf("invalid map key [%s]: %v", key, err)
+		}
+		mv := reflect.New(t.Elem()).Elem()
+		if err = bindNode(mv, child, cate, timeFormat); err != nil {
+			return
+		}
+		m.SetMapIndex(mk, mv)
+	}
+	v.Set(m)
+	return
+}
+
+// nodeToAny converts node for an `any` value, a single value is a string,
+// repeated values are []string and children are map[string]any.
+func nodeToAny(node *valueNode) any {
+	if len(node.children) == 0 {
+		if len(node.values) == 1 {
+			return node.values[0]
+		}
+		return node.values
+	}
+	m := make(map[string]any, len(node.children))
+	for key, child := range node.children {
+		m[key] = nodeToAny(child)
+	}
+	return m
+}
+
+// isNestedType reports whether t is a struct whose fields are bound one by one,
+// rather than a value converted from a single string like time.Time.
+func isNestedType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if _, ok := converterOf(t); ok {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	if pt.Implements(typeOfTextUnmarshaler) || isTypedParam(t) || pt.Implements(typeOfOptional) {
+		return false
+	}
+	_, ok := pt.MethodByName("ParseValue")
+	return !ok
+}
+
+// ---------------------------------------------------------------------------
+
+// setDefaults sets the fields of the struct v to the value of their `default` tag, like
+//
+//	Size int `form:"size" json:"size" default:"20"`
+//
+// It runs before binding, so a value present in the request overrides the default.
+// Slices take a comma separated list, nested structs are walked unless they are nil pointers.
+func setDefaults(v reflect.Value) (err error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		sfv := v.Field(i)
+		if !hasDefault(sf) {
+			if isNestedType(indirectType(sf.Type)) {
+				if err = setDefaults(sfv); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		if isTypedParam(sf.Type) {
+			if sf.Type.Kind() == reflect.Ptr {
+				if sfv.IsNil() {
+					sfv.Set(reflect.New(sf.Type.Elem()))
+				}
+				sfv = sfv.Elem()
+			}
+			sfv = sfv.Addr().Interface().(param.Typed).ParamValue()
+		}
+		if opt, ok := optionalOf(sfv); ok {
+			opt.MarkPresent(false)
+			sfv = opt.OptionalTarget()
+		}
+		values := []string{sf.Tag.Get("default")}
+		if isMultiValue(sfv.Type()) {
+			values = strings.Split(values[0], ",")
+		}
+		if err = strconvParseValues(sfv, values, sf.Tag.Get("time_format")); err != nil {
+			return errors.Info(err, "formutil.ParseValue: parse default of struct field -", sf.Name).Detail(err)
+		}
+	}
+	return
+}
+
+func hasDefault(sf reflect.StructField) bool {
+	_, ok := sf.Tag.Lookup("default")
+	return ok
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
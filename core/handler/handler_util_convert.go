@@ -0,0 +1,140 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConverterFunc converts a string of the request into a value of the registered type
+type ConverterFunc = func(str string) (reflect.Value, error)
+
+var (
+	converters     = make(map[reflect.Type]ConverterFunc)
+	convertersLock sync.RWMutex
+)
+
+var (
+	typeOfTime            = reflect.TypeOf(time.Time{})
+	typeOfDuration        = reflect.TypeOf(time.Duration(0))
+	typeOfJsonNumber      = reflect.TypeOf(json.Number(""))
+	typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// RegisterConverter registers how to convert a string of the query, form, header or path into T,
+// it takes precedence over the built-in conversions, like
+//
+//	handler.RegisterConverter(func(str string) (Money, error) { return ParseMoney(str) })
+func RegisterConverter[T any](f func(str string) (T, error)) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	RegisterConverterByType(typ, func(str string) (reflect.Value, error) {
+		t, err := f(str)
+		return reflect.ValueOf(&t).Elem(), err
+	})
+}
+
+// RegisterConverterByType is the reflect version of RegisterConverter
+func RegisterConverterByType(typ reflect.Type, f ConverterFunc) {
+	convertersLock.Lock()
+	defer convertersLock.Unlock()
+	converters[typ] = f
+}
+
+func converterOf(typ reflect.Type) (f ConverterFunc, ok bool) {
+	convertersLock.RLock()
+	defer convertersLock.RUnlock()
+	f, ok = converters[typ]
+	return
+}
+
+// convertValue handles the types that can not be converted by kind, ok is false if v is none of them.
+//
+// The order is: registered converters, param.Optional, time.Time, time.Duration, json.Number, encoding.TextUnmarshaler
+// (net.IP, big.Int, big.Float, big.Rat ...).
+func convertValue(v reflect.Value, str string, timeFormat string) (ok bool, err error) {
+	typ := v.Type()
+	if f, found := converterOf(typ); found {
+		var value reflect.Value
+		if value, err = f(str); err == nil {
+			v.Set(value)
+		}
+		return true, err
+	}
+	if opt, isOpt := optionalOf(v); isOpt {
+		target := opt.OptionalTarget()
+		if str == "" && target.Kind() != reflect.String {
+			opt.MarkPresent(true)
+			return true, nil
+		}
+		opt.MarkPresent(false)
+		return true, strconvParseValueFormat(target, str, timeFormat)
+	}
+	switch typ {
+	case typeOfTime:
+		var t time.Time
+		if t, err = parseTime(str, timeFormat); err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return true, err
+	case typeOfDuration:
+		var d time.Duration
+		if d, err = time.ParseDuration(str); err == nil {
+			v.SetInt(int64(d))
+		}
+		return true, err
+	case typeOfJsonNumber:
+		if _, err = strconv.ParseFloat(str, 64); err == nil {
+			v.SetString(str)
+		}
+		return true, err
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(typeOfTextUnmarshaler) {
+		return true, v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+	return false, nil
+}
+
+// parseTime parses str by the layout of the `time_format` tag, RFC 3339 by default.
+// `unix`, `unixmilli` and `unixnano` parse an integer timestamp.
+func parseTime(str string, timeFormat string) (t time.Time, err error) {
+	if str == "" {
+		return
+	}
+	switch timeFormat {
+	case "":
+		return time.Parse(time.RFC3339, str)
+	case "unix", "unixmilli", "unixnano":
+		var ts int64
+		if ts, err = strconv.ParseInt(str, 10, 64); err != nil {
+			return
+		}
+		switch timeFormat {
+		case "unix":
+			return time.Unix(ts, 0), nil
+		case "unixmilli":
+			return time.UnixMilli(ts), nil
+		default:
+			return time.Unix(0, ts), nil
+		}
+	}
+	return time.ParseInLocation(timeFormat, str, time.Local)
+}
+
+// isMultiValue reports whether every value of a repeated key is bound into t,
+// []byte, net.IP and types with a converter or encoding.TextUnmarshaler take only one value.
+func isMultiValue(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	if _, ok := converterOf(t); ok {
+		return false
+	}
+	return !reflect.PtrTo(t).Implements(typeOfTextUnmarshaler)
+}
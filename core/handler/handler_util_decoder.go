@@ -0,0 +1,119 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// DecoderFunc decodes the request body into v, a pointer to the parameter
+type DecoderFunc = func(body []byte, v any) error
+
+var (
+	decoders     = make(map[string]DecoderFunc)
+	decodersLock sync.RWMutex
+)
+
+func init() {
+	RegisterDecoder(constant.MIMEApplicationXML, xml.Unmarshal)
+	RegisterDecoder(constant.MIMETextXML, xml.Unmarshal)
+	RegisterDecoder(constant.MIMETextPlain, decodeText)
+	RegisterDecoder(constant.MIMEOctetStream, decodeBytes)
+	RegisterDecoder(constant.MIMEApplicationMsgpack, utils.MsgpackUnmarshal)
+	RegisterDecoder("application/x-msgpack", utils.MsgpackUnmarshal)
+	RegisterDecoder(constant.MIMEApplicationCBOR, utils.CborUnmarshal)
+}
+
+// RegisterDecoder registers how to bind a request body of the media type, like
+//
+//	handler.RegisterDecoder("application/yaml", yaml.Unmarshal)
+//
+// JSON, JSON Merge Patch and forms are built in. A body of any other unregistered media type is rejected with 415.
+func RegisterDecoder(mediaType string, f DecoderFunc) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	decoders[strings.ToLower(mediaType)] = f
+}
+
+func decoderOf(mediaType string) (f DecoderFunc, ok bool) {
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	f, ok = decoders[mediaType]
+	return
+}
+
+// mediaTypeOf returns the lower-cased media type of the Content-Type without parameters, like text/plain
+func mediaTypeOf(req *fasthttp.Request) string {
+	ct := string(req.Header.Peek(constant.HeaderContentType))
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// decodeBody binds the body by the decoder registered for the Content-Type
+func decodeBody(req *fasthttp.Request, v reflect.Value) error {
+	mediaType := mediaTypeOf(req)
+	f, ok := decoderOf(mediaType)
+	if !ok {
+		return constant.NewError(constant.StatusUnsupportedMediaType,
+			fmt.Sprintf("unsupported Content-Type [%s]", mediaType))
+	}
+	if err := f(req.Body(), v.Interface()); err != nil {
+		return constant.NewError(constant.StatusBadRequest, fmt.Sprintf("invalid %s body: %v", mediaType, err))
+	}
+	return nil
+}
+
+// decodeText binds a text/plain body into a string, []byte, encoding.TextUnmarshaler or scalar
+func decodeText(body []byte, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	if err := decodeBytes(body, v); err == nil {
+		return nil
+	}
+	if u, ok := v.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(body)
+	}
+	if rv.Kind() == reflect.Struct || rv.Kind() == reflect.Map {
+		return fmt.Errorf("can not bind text into %v", rv.Type())
+	}
+	return strconvParseValue(rv, string(body))
+}
+
+// decodeBytes binds the raw body into a string or []byte
+func decodeBytes(body []byte, v any) error {
+	switch ptr := v.(type) {
+	case *string:
+		*ptr = string(body)
+	case *[]byte:
+		*ptr = append([]byte(nil), body...)
+	default:
+		return fmt.Errorf("can not bind raw bytes into %T", v)
+	}
+	return nil
+}
+
+// rawBodyOf returns the body for a []byte or io.Reader parameter,
+// the reader is the request body stream if fasthttp.Server.StreamRequestBody is enabled.
+func rawBodyOf(ctx *fasthttp.RequestCtx, typ reflect.Type) reflect.Value {
+	if typ == typeOfBytes {
+		return reflect.ValueOf(append([]byte(nil), ctx.Request.Body()...))
+	}
+	var r io.Reader
+	if r = ctx.RequestBodyStream(); r == nil {
+		r = bytes.NewReader(ctx.Request.Body())
+	}
+	return reflect.ValueOf(&r).Elem()
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type decoderReq struct {
+	Name string `xml:"name" form:"name"`
+	Age  int    `xml:"age" form:"age"`
+}
+
+func (c *typedController) PostV1Xml(req *decoderReq) (error, *decoderReq) {
+	return nil, req
+}
+
+func (c *typedController) PostV1Count(count param.Body[int]) (error, int) {
+	return nil, count.Get()
+}
+
+func (c *typedController) PostV1Bytes(body []byte, r io.Reader) (error, map[string]any) {
+	streamed, err := io.ReadAll(r)
+	return err, map[string]any{"bytes": string(body), "reader": string(streamed)}
+}
+
+func TestDecodeBody(t *testing.T) {
+	h := newTypedHandler(t, "PostV1Xml")
+	ctx := newRequestCtx(fasthttp.MethodPost, "/v1/xml", constant.MIMEApplicationXMLCharsetUTF8,
+		`<decoderReq><name>jet</name><age>3</age></decoderReq>`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"Name":"jet","Age":3}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/xml", "application/x-unknown", `name=jet`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusUnsupportedMediaType, ctx.Response.StatusCode())
+
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/xml", constant.MIMETextPlain, `jet`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+
+	// without a body the query string is still bound
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/xml?name=jet", "application/x-unknown", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"Name":"jet","Age":0}`, string(ctx.Response.Body()))
+
+	RegisterDecoder("application/x-csv-pair", func(body []byte, v any) error {
+		pair := strings.SplitN(string(body), ",", 2)
+		req := v.(*decoderReq)
+		req.Name = pair[0]
+		return strconvParseValue(reflect.ValueOf(&req.Age).Elem(), pair[1])
+	})
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/xml", "application/x-csv-pair", `jet,5`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"Name":"jet","Age":5}`, string(ctx.Response.Body()))
+
+	body, _ := utils.CborMarshal(map[string]any{"Name": "jet", "Age": 7})
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/xml", constant.MIMEApplicationCBOR, string(body))
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"Name":"jet","Age":7}`, string(ctx.Response.Body()))
+
+	h = newTypedHandler(t, "PostV1Count")
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/count", constant.MIMETextPlainCharsetUTF8, `42`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, "42", string(ctx.Response.Body()))
+
+	h = newTypedHandler(t, "PostV1Bytes")
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/bytes", constant.MIMEOctetStream, `raw`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"bytes":"raw","reader":"raw"}`, string(ctx.Response.Body()))
+}
@@ -0,0 +1,252 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EncoderFunc encodes the data returned by a handler method as a response body of the media type
+type EncoderFunc = func(data any) ([]byte, error)
+
+var (
+	encoders         = make(map[string]EncoderFunc)
+	encoderTypes     []string // in the order of registration, which breaks ties of the Accept header
+	encodables       = make(map[string]func(data any) bool)
+	defaultMediaType = constant.MIMEApplicationJSON
+	encodersLock     sync.RWMutex
+)
+
+func init() {
+	RegisterEncoder(constant.MIMEApplicationJSON, utils.ObjToByte)
+	RegisterEncoderOf(constant.MIMEApplicationXML, xml.Marshal, xmlEncodable)
+	RegisterEncoderOf(constant.MIMETextXML, xml.Marshal, xmlEncodable)
+	RegisterEncoder(constant.MIMETextPlain, encodeText)
+	RegisterEncoder(constant.MIMEApplicationMsgpack, utils.MsgpackMarshal)
+	RegisterEncoder("application/x-msgpack", utils.MsgpackMarshal)
+	RegisterEncoder(constant.MIMEApplicationCBOR, utils.CborMarshal)
+	// a csv is sent as an attachment named after the path, see csvFilename
+	RegisterEncoderOf(constant.MIMETextCSV, utils.CsvMarshal, csvEncodable)
+}
+
+// RegisterEncoder registers how to encode a response of the media type, which is chosen by the Accept header, like
+//
+//	handler.RegisterEncoder("application/yaml", yaml.Marshal)
+func RegisterEncoder(mediaType string, f EncoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	if _, ok := encoders[mediaType]; !ok {
+		encoderTypes = append(encoderTypes, mediaType)
+	}
+	encoders[mediaType] = f
+	delete(encodables, mediaType)
+}
+
+// RegisterEncoderOf registers an encoder like RegisterEncoder, which is only negotiated for the data
+// accepted by encodable, like text/csv for structs and slices of structs
+func RegisterEncoderOf(mediaType string, f EncoderFunc, encodable func(data any) bool) {
+	RegisterEncoder(mediaType, f)
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	encodables[strings.ToLower(mediaType)] = encodable
+}
+
+// xmlEncodable offers xml for structs and slices of structs, xml.Marshal fails on maps
+func xmlEncodable(data any) bool {
+	if _, isErr := data.(error); isErr || data == nil {
+		return false
+	}
+	t := reflect.TypeOf(data)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		for t = t.Elem(); t.Kind() == reflect.Ptr; {
+			t = t.Elem()
+		}
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// csvFilename returns the download name of a csv negotiated by the Accept header, the last segment of the path,
+// like orders.csv for /v1/orders, as jet.CSVOf names its attachment
+func csvFilename(path []byte) string {
+	name := []byte(strings.TrimSuffix(string(path), "/"))
+	if i := bytes.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 {
+		return "export.csv"
+	}
+	return string(name) + ".csv"
+}
+
+// csvEncodable offers csv for the results fitting rows, and never for errors
+func csvEncodable(data any) bool {
+	_, isErr := data.(error)
+	return !isErr && utils.CsvEncodable(data)
+}
+
+// SetDefaultMediaType sets the media type of a response when the request has no Accept header or accepts anything,
+// application/json by default. The media type must have a registered encoder.
+func SetDefaultMediaType(mediaType string) {
+	mediaType = strings.ToLower(mediaType)
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	if _, ok := encoders[mediaType]; !ok {
+		panic("handler.SetDefaultMediaType: no encoder is registered for " + mediaType)
+	}
+	defaultMediaType = mediaType
+}
+
+// negotiate chooses the encoder of data by the Accept header: the media type of the highest q-value,
+// the default one on a tie, else the most specific range and then the order of registration.
+// It is 406 if none of the registered media types encoding data is acceptable.
+func negotiate(accept string, data any) (mediaType string, f EncoderFunc, err error) {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		return defaultMediaType, encoders[defaultMediaType], nil
+	}
+	ranges := parseAccept(accept)
+	var (
+		bestQ    float64
+		bestSpec int
+	)
+	for _, typ := range encoderTypes {
+		if encodable, ok := encodables[typ]; ok && !encodable(data) {
+			continue
+		}
+		q, spec := qualityOf(ranges, typ)
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && mediaType != defaultMediaType && (typ == defaultMediaType || spec > bestSpec) {
+			mediaType, bestQ, bestSpec = typ, q, spec
+		}
+	}
+	if mediaType == "" {
+		return "", nil, constant.NewError(constant.StatusNotAcceptable,
+			fmt.Sprintf("none of the media types [%s] is acceptable", strings.Join(encoderTypes, ", ")))
+	}
+	return mediaType, encoders[mediaType], nil
+}
+
+// acceptRange is a media range of the Accept header, like text/* or */* with its q-value
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) (ranges []acceptRange) {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		r := acceptRange{mediaType: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		if r.mediaType == "" {
+			continue
+		}
+		if r.mediaType == "*" {
+			r.mediaType = "*/*"
+		}
+		for _, field := range fields[1:] {
+			k, v, _ := strings.Cut(field, "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+// qualityOf returns the q-value of the most specific range that matches the media type, 0 if none matches,
+// and its specificity, 2 for the media type, 1 for type/* and 0 for */*
+func qualityOf(ranges []acceptRange, mediaType string) (q float64, specificity int) {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	specificity = -1
+	for _, r := range ranges {
+		s := -1
+		switch r.mediaType {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return
+}
+
+// encodeResponse writes data in the media type negotiated by the Accept header of the request
+func encodeResponse(ctx *fasthttp.RequestCtx, data any) error {
+	ctx.Response.Header.Add(constant.HeaderVary, constant.HeaderAccept)
+	mediaType, f, err := negotiate(string(ctx.Request.Header.Peek(constant.HeaderAccept)), data)
+	if err != nil {
+		return err
+	}
+	body, err := f(data)
+	if err != nil && mediaType != defaultMediaType {
+		// the default media type is used if the client accepts it too
+		if q, _ := qualityOf(parseAccept(string(ctx.Request.Header.Peek(constant.HeaderAccept))), defaultMediaType); q > 0 {
+			encodersLock.RLock()
+			mediaType, f = defaultMediaType, encoders[defaultMediaType]
+			encodersLock.RUnlock()
+			body, err = f(data)
+		}
+	}
+	if err != nil {
+		if mediaType != defaultMediaType {
+			// the client asked for a media type the result can not be encoded in, which is not a server error
+			return constant.NewError(constant.StatusNotAcceptable, fmt.Sprintf("encode %s: %v", mediaType, err))
+		}
+		return constant.NewError(constant.StatusInternalServerError, fmt.Sprintf("encode %s: %v", mediaType, err))
+	}
+	if mediaType == constant.MIMETextCSV && len(ctx.Response.Header.Peek(constant.HeaderContentDisposition)) == 0 {
+		ctx.Response.Header.Set(constant.HeaderContentDisposition, `attachment; filename="`+csvFilename(ctx.Path())+`"`)
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		mediaType += "; charset=utf-8"
+	}
+	ctx.SetContentType(mediaType)
+	ctx.SetBody(body)
+	return nil
+}
+
+// encodeText writes strings, bytes, encoding.TextMarshaler and fmt.Stringer as they are, others by %v
+func encodeText(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case encoding.TextMarshaler:
+		return v.MarshalText()
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	}
+	return []byte(fmt.Sprintf("%v", data)), nil
+}
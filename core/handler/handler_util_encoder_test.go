@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":           constant.MIMEApplicationJSON,
+		"*/*":        constant.MIMEApplicationJSON,
+		"text/plain": constant.MIMETextPlain,
+		"application/xml;q=0.9, text/plain;q=0.5":                         constant.MIMETextPlain,
+		"text/*;q=0.8, text/xml;q=0":                                      constant.MIMETextPlain,
+		"text/html, application/*;q=0.2":                                  constant.MIMEApplicationJSON,
+		"TEXT/PLAIN; charset=utf-8":                                       constant.MIMETextPlain,
+		"text/plain, application/json;q=0.1":                              constant.MIMETextPlain,
+		"application/msgpack, */*;q=0.1":                                  constant.MIMEApplicationMsgpack,
+		"application/cbor, application/json;q=0.2":                        constant.MIMEApplicationCBOR,
+		"application/msgpack, */*":                                        constant.MIMEApplicationJSON,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": constant.MIMEApplicationJSON,
+	} {
+		mediaType, _, err := negotiate(accept, nil)
+		assert.NoError(t, err, accept)
+		assert.Equal(t, want, mediaType, accept)
+	}
+	// the encoders of some results only
+	for accept, want := range map[string]string{
+		"text/csv, application/json;q=0.1":                                constant.MIMETextCSV,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": constant.MIMEApplicationXML,
+	} {
+		mediaType, _, err := negotiate(accept, encodedUser{})
+		assert.NoError(t, err, accept)
+		assert.Equal(t, want, mediaType, accept)
+	}
+	for _, accept := range []string{"text/html", "image/*", "application/json;q=0, */*;q=0"} {
+		_, _, err := negotiate(accept, nil)
+		var e *constant.Error
+		if assert.ErrorAs(t, err, &e, accept) {
+			assert.Equal(t, constant.StatusNotAcceptable, e.Code)
+		}
+	}
+}
+
+type encodedUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func (u encodedUser) String() string { return "user " + u.Name }
+
+func (c *typedController) GetV1Encoded() (error, encodedUser) {
+	return nil, encodedUser{Name: "jet"}
+}
+
+func (c *typedController) GetV1EncodedMap() map[string]any {
+	return map[string]any{"name": "jet"}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	h := newTypedHandler(t, "GetV1Encoded")
+	for accept, want := range map[string][2]string{
+		"":                {constant.MIMEApplicationJSON, `{"name":"jet"}`},
+		"application/xml": {constant.MIMEApplicationXML, `<encodedUser><name>jet</name></encodedUser>`},
+		"text/plain":      {constant.MIMETextPlainCharsetUTF8, `user jet`},
+		"text/csv":        {"text/csv; charset=utf-8", "name\njet\n"},
+	} {
+		ctx := newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+		ctx.Request.Header.Set(constant.HeaderAccept, accept)
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, want[0], string(ctx.Response.Header.ContentType()), accept)
+		assert.Equal(t, want[1], string(ctx.Response.Body()), accept)
+		assert.Equal(t, constant.HeaderAccept, string(ctx.Response.Header.Peek(constant.HeaderVary)))
+	}
+
+	for mediaType, unmarshal := range map[string]func([]byte, any) error{
+		constant.MIMEApplicationMsgpack: utils.MsgpackUnmarshal,
+		constant.MIMEApplicationCBOR:    utils.CborUnmarshal,
+	} {
+		ctx := newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+		ctx.Request.Header.Set(constant.HeaderAccept, mediaType)
+		h.ServeHTTP(ctx, nil)
+		var user encodedUser
+		assert.NoError(t, unmarshal(ctx.Response.Body(), &user), mediaType)
+		assert.Equal(t, "jet", user.Name, mediaType)
+	}
+
+	ctx := newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "text/csv")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, `attachment; filename="encoded.csv"`, string(ctx.Response.Header.Peek(constant.HeaderContentDisposition)))
+	assert.Equal(t, "export.csv", csvFilename([]byte("/")))
+	assert.Equal(t, "a_b.csv", csvFilename([]byte("/v1/a b/")))
+
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "image/png")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusNotAcceptable, ctx.Response.StatusCode())
+
+	// xml is only negotiated for structs, as xml.Marshal fails on maps
+	h = newTypedHandler(t, "GetV1EncodedMap")
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded/map", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "application/xml")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusNotAcceptable, ctx.Response.StatusCode())
+	// csv is only negotiated for structs, and errors are never written as csv
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded/map", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "text/csv")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusNotAcceptable, ctx.Response.StatusCode())
+	assert.Equal(t, constant.MIMETextPlainCharsetUTF8, string(ctx.Response.Header.ContentType()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded/map", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "text/csv, application/msgpack;q=0.5")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, constant.MIMEApplicationMsgpack, string(ctx.Response.Header.ContentType()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded/map", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `{"name":"jet"}`, string(ctx.Response.Body()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded/map", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "application/xml, application/json;q=0.5")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, constant.MIMEApplicationJSON, string(ctx.Response.Header.ContentType()))
+
+	// a failing encoder falls back to the default media type if it is acceptable, else it is not acceptable
+	h = newTypedHandler(t, "GetV1Encoded")
+	RegisterEncoder("application/x-broken", func(data any) ([]byte, error) {
+		return nil, errors.New("broken")
+	})
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "application/x-broken, application/json;q=0.5")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, `{"name":"jet"}`, string(ctx.Response.Body()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+	ctx.Request.Header.Set(constant.HeaderAccept, "application/x-broken")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusNotAcceptable, ctx.Response.StatusCode())
+
+	RegisterEncoder("application/x-upper", func(data any) ([]byte, error) {
+		return []byte("USER JET"), nil
+	})
+	SetDefaultMediaType("application/x-upper")
+	defer SetDefaultMediaType(constant.MIMEApplicationJSON)
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/encoded", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, "USER JET", string(ctx.Response.Body()))
+}
@@ -0,0 +1,100 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// EnvelopeFunc wraps the result of a handler method into the response body,
+// data is the successful result if err is nil.
+type EnvelopeFunc = func(ctx *fasthttp.RequestCtx, data any, err error) any
+
+var envelope EnvelopeFunc
+
+// SetEnvelope wraps every successful result and every error of the handler methods, like
+//
+//	handler.SetEnvelope(handler.DefaultEnvelope)
+//
+// The routes configured with RouteConfig.RawResponse are not wrapped, nil removes the envelope.
+// Errors keep their status code, and the envelope takes precedence over the ErrorRendererFunc.
+// A wrapped result gets no weak ETag hashing the body, as it varies by request, but the one of an ETagger.
+// It must be called before the server starts.
+func SetEnvelope(f EnvelopeFunc) {
+	envelope = f
+}
+
+// Envelope is the body written by DefaultEnvelope
+type Envelope struct {
+	RequestId string `json:"request_id,omitempty"`
+	Code      int    `json:"code"` // 200 for success, otherwise the status code of the error
+	Message   string `json:"message,omitempty"`
+	Data      any    `json:"data,omitempty"`
+}
+
+// DefaultEnvelope wraps the result into an Envelope.
+// The data of an error is the fields failing validation or the details of a *constant.Error,
+// and the message of an error without a status code is the status text, as it may leak internals.
+func DefaultEnvelope(ctx *fasthttp.RequestCtx, data any, err error) any {
+	env := &Envelope{RequestId: RequestIdOf(ctx), Code: constant.StatusOK, Data: data}
+	if err == nil {
+		return env
+	}
+	env.Code = statusOf(ctx, err)
+	var e *constant.Error
+	var sc StatusCoder
+	switch {
+	case errors.As(err, &e):
+		env.Message, env.Data = e.Message, e.Details
+	case errors.As(err, &sc):
+		env.Message = err.Error()
+	default:
+		env.Message = constant.StatusMessage(env.Code)
+	}
+	if fields := utils.FieldErrors(err); fields != nil {
+		env.Data = fields
+	}
+	return env
+}
+
+// RequestIdOf returns the request id of the request, see xlog.Logger.ReqId
+func RequestIdOf(ctx *fasthttp.RequestCtx) string {
+	reqId, _ := ctx.UserValue(reqIdKey).(string)
+	return reqId
+}
+
+// write writes a successful result, wrapped by the envelope unless the route is raw
+func (h handler) write(ctx *fasthttp.RequestCtx, data any) {
+	if w, ok := data.(ResponseWriter); ok {
+		if err := w.WriteResponse(ctx); err != nil {
+			h.fail(ctx, err)
+			return
+		}
+		h.hashETag(ctx, data)
+		return
+	}
+	if envelope != nil && !h.config.RawResponse {
+		// the body of the envelope varies by request, like its request id, and is not hashed
+		writeData(ctx, envelope(ctx, data, nil))
+		return
+	}
+	writeData(ctx, data)
+	h.hashETag(ctx, data)
+}
+
+// fail writes an error, wrapped by the envelope unless the route is raw
+func (h handler) fail(ctx *fasthttp.RequestCtx, err error) {
+	if envelope != nil && !h.config.RawResponse {
+		ctx.SetStatusCode(statusOf(ctx, err))
+		ctx.Response.Header.SetServer("JetServer")
+		if encodeResponse(ctx, envelope(ctx, nil, err)) == nil {
+			return
+		}
+	}
+	failWithError(ctx, err)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type envelopeController struct{}
+
+func (c *envelopeController) GetV1User() (map[string]any, error) {
+	return map[string]any{"name": "jet"}, nil
+}
+func (c *envelopeController) GetV1Missing() (map[string]any, error) {
+	return nil, constant.NewError(constant.StatusNotFound, "user not found").WithDetails("id 7")
+}
+func (c *envelopeController) GetV1Plain() error       { return errors.New("connection refused") }
+func (c *envelopeController) GetV1Health() string     { return "ok" }
+func (c *envelopeController) GetV1HealthError() error { return errors.New("down") }
+
+func TestEnvelope(t *testing.T) {
+	SetEnvelope(DefaultEnvelope)
+	defer SetEnvelope(nil)
+	ConfigureRoute((*envelopeController).GetV1Health, RouteConfig{RawResponse: true})
+	ConfigureRoute((*envelopeController).GetV1HealthError, RouteConfig{RawResponse: true})
+
+	reqId := regexp.MustCompile(`"request_id":"[^"]+",`)
+	rcvr := reflect.ValueOf(&envelopeController{})
+	for name, want := range map[string]struct {
+		status int
+		body   string
+	}{
+		"GetV1User":        {fasthttp.StatusOK, `{"code":200,"data":{"name":"jet"}}`},
+		"GetV1Missing":     {fasthttp.StatusNotFound, `{"code":404,"message":"user not found","data":"id 7"}`},
+		"GetV1Plain":       {fasthttp.StatusInternalServerError, `{"code":500,"message":"Internal Server Error"}`},
+		"GetV1Health":      {fasthttp.StatusOK, `ok`},
+		"GetV1HealthError": {fasthttp.StatusInternalServerError, `down`},
+	} {
+		method, _ := rcvr.Type().MethodByName(name)
+		h, err := HandlerCreator{}.New(&rcvr, &method)
+		assert.NoError(t, err)
+		ctx := newRequestCtx(fasthttp.MethodGet, "/", "", "")
+		h.ServeHTTP(ctx, nil)
+		assert.Equal(t, want.status, ctx.Response.StatusCode(), name)
+		body := string(ctx.Response.Body())
+		if want.body[0] == '{' {
+			assert.Regexp(t, reqId, body, name)
+			body = reqId.ReplaceAllString(body, "")
+		}
+		assert.Equal(t, want.body, body, name)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETagger is implemented by return values knowing their version, like a row with a version column.
+// Their ETag is sent instead of hashing the body, see FormatETag. The If-Match of a change is not checked against it,
+// as the result is only known once the change is done, see CheckPrecondition.
+type ETagger interface {
+	ETag() string
+}
+
+// FormatETag returns the ETag header of a version tag, which is quoted unless it already is, like
+//
+//	v3        => "v3"
+//	W/"v3"    => W/"v3"
+func FormatETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
+
+// NotModified reports whether a GET or HEAD request is answered by 304 Not Modified, by the If-None-Match
+// against the ETag of the response, or else the If-Modified-Since against the Last-Modified of the response
+func NotModified(ctx *fasthttp.RequestCtx) bool {
+	if !ctx.IsGet() && !ctx.IsHead() {
+		return false
+	}
+	if ifNoneMatch := string(ctx.Request.Header.Peek(constant.HeaderIfNoneMatch)); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, string(ctx.Response.Header.Peek(constant.HeaderETag)), false)
+	}
+	ifModifiedSince := ctx.Request.Header.Peek(constant.HeaderIfModifiedSince)
+	lastModified := ctx.Response.Header.Peek(constant.HeaderLastModified)
+	if len(ifModifiedSince) == 0 || len(lastModified) == 0 {
+		return false
+	}
+	since, err := http.ParseTime(string(ifModifiedSince))
+	if err != nil {
+		return false
+	}
+	modTime, err := http.ParseTime(string(lastModified))
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
+
+// CheckPrecondition returns 412 if the If-Match or If-None-Match headers of an unsafe request do not hold
+// for the current ETag of the resource, an empty etag if it does not exist. It is called before the change, like
+//
+//	func (c *UserController) PutV1User0(ctx *fasthttp.RequestCtx, id param.Path[int64], req *UpdateUserReq) (*User, error) {
+//		user, err := c.users.Get(id.Get())
+//		if err != nil {
+//			return nil, err
+//		}
+//		if err = handler.CheckPrecondition(ctx, user.ETag()); err != nil {
+//			return nil, err
+//		}
+//		return c.users.Update(user, req)
+//	}
+//
+// If-Match requires a strong match, "*" matches any existing resource, and If-None-Match: * only lets
+// the resource be created. Safe requests are answered by NotModified instead.
+// The preconditions of PUT, PATCH and DELETE are only checked this way, by design: they must hold for
+// the current version before the change, which only the method can load, not for the result after it.
+func CheckPrecondition(ctx *fasthttp.RequestCtx, etag string) error {
+	switch string(ctx.Method()) {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return nil
+	}
+	if etag != "" {
+		etag = FormatETag(etag)
+	}
+	if ifMatch := string(ctx.Request.Header.Peek(constant.HeaderIfMatch)); ifMatch != "" &&
+		!etagMatches(ifMatch, etag, true) {
+		return constant.NewError(constant.StatusPreconditionFailed, "the resource has been modified")
+	}
+	if ifNoneMatch := string(ctx.Request.Header.Peek(constant.HeaderIfNoneMatch)); ifNoneMatch != "" &&
+		etagMatches(ifNoneMatch, etag, false) {
+		return constant.NewError(constant.StatusPreconditionFailed, "the resource already exists")
+	}
+	return nil
+}
+
+// etagMatches reports whether the etag is in the list of an If-Match or If-None-Match header,
+// by the strong comparison of RFC 9110, section 8.8.3.2 if strong, otherwise by the weak one
+func etagMatches(list, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// weakETag returns a weak ETag hashing the content type and the body
+func weakETag(contentType, body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(contentType)
+	_, _ = h.Write(body)
+	return `W/"` + strconv.FormatInt(int64(len(body)), 16) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// hashETag sets a weak ETag hashing the body of a successful GET or HEAD response, unless the result is an ETagger
+// or the method set one. It is called once the body is written, so that it is encoded once.
+func (h handler) hashETag(ctx *fasthttp.RequestCtx, data any) {
+	resp := &ctx.Response
+	if _, ok := data.(ETagger); ok || h.config.DisableETag || !ctx.IsGet() && !ctx.IsHead() ||
+		resp.StatusCode() != constant.StatusOK || resp.IsBodyStream() || len(resp.Header.Peek(constant.HeaderETag)) > 0 {
+		return
+	}
+	resp.Header.Set(constant.HeaderETag, weakETag(resp.Header.ContentType(), resp.Body()))
+}
+
+// conditional sets the ETag of an ETagger result, unless the method or its Responder set one,
+// and answers a conditional GET or HEAD of a successful response with 304
+func (h handler) conditional(ctx *fasthttp.RequestCtx, data any) {
+	resp := &ctx.Response
+	if resp.StatusCode() != constant.StatusOK || resp.IsBodyStream() {
+		return
+	}
+	if tagger, ok := data.(ETagger); ok && len(resp.Header.Peek(constant.HeaderETag)) == 0 {
+		if tag := tagger.ETag(); tag != "" {
+			resp.Header.Set(constant.HeaderETag, FormatETag(tag))
+		}
+	}
+	if NotModified(ctx) {
+		resp.ResetBody()
+		resp.SetStatusCode(constant.StatusNotModified)
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type versionedUser struct {
+	Name    string `json:"name"`
+	Version int    `json:"-"`
+}
+
+func (u *versionedUser) ETag() string {
+	return "v" + string(rune('0'+u.Version))
+}
+
+type etagController struct{}
+
+func (c *etagController) GetV1User() (map[string]any, error) {
+	return map[string]any{"name": "jet"}, nil
+}
+func (c *etagController) GetV1Versioned() (*versionedUser, error) {
+	return &versionedUser{Name: "jet", Version: 3}, nil
+}
+func (c *etagController) GetV1Random() string { return "changes on every request" }
+func (c *etagController) PostV1User() (map[string]any, error) {
+	return map[string]any{"name": "jet"}, nil
+}
+func (c *etagController) PutV1User(ctx *fasthttp.RequestCtx) (*versionedUser, error) {
+	user := &versionedUser{Name: "jet", Version: 3}
+	if err := CheckPrecondition(ctx, user.ETag()); err != nil {
+		return nil, err
+	}
+	user.Version++
+	return user, nil
+}
+
+func serveETag(t *testing.T, name, method string, header map[string]string) *fasthttp.RequestCtx {
+	rcvr := reflect.ValueOf(&etagController{})
+	m, _ := rcvr.Type().MethodByName(name)
+	h, err := HandlerCreator{}.New(&rcvr, &m)
+	assert.NoError(t, err)
+	ctx := newRequestCtx(method, "/", "", "")
+	for k, v := range header {
+		ctx.Request.Header.Set(k, v)
+	}
+	h.ServeHTTP(ctx, nil)
+	return ctx
+}
+
+func TestETag(t *testing.T) {
+	ctx := serveETag(t, "GetV1User", fasthttp.MethodGet, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	etag := string(ctx.Response.Header.Peek(constant.HeaderETag))
+	assert.Regexp(t, `^W/"e-[0-9a-f]+"$`, etag)
+
+	ctx = serveETag(t, "GetV1User", fasthttp.MethodGet, map[string]string{"If-None-Match": `"other", ` + etag})
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+	assert.Equal(t, etag, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+	assert.Empty(t, ctx.Response.Body())
+
+	// another media type is another representation
+	ctx = serveETag(t, "GetV1User", fasthttp.MethodGet, map[string]string{"Accept": "text/plain", "If-None-Match": etag})
+	assert.NotEqual(t, etag, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+
+	ctx = serveETag(t, "GetV1User", fasthttp.MethodGet, map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+
+	ctx = serveETag(t, "PostV1User", fasthttp.MethodPost, map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek(constant.HeaderETag))
+
+	ctx = serveETag(t, "GetV1Versioned", fasthttp.MethodGet, nil)
+	assert.Equal(t, `"v3"`, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+	ctx = serveETag(t, "GetV1Versioned", fasthttp.MethodGet, map[string]string{"If-None-Match": `W/"v3"`})
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+
+	ConfigureRoute((*etagController).GetV1Random, RouteConfig{DisableETag: true})
+	ctx = serveETag(t, "GetV1Random", fasthttp.MethodGet, nil)
+	assert.Empty(t, ctx.Response.Header.Peek(constant.HeaderETag))
+}
+
+func TestETagEnvelope(t *testing.T) {
+	SetEnvelope(DefaultEnvelope)
+	defer SetEnvelope(nil)
+	// the envelope varies by request, so only an ETagger has an etag
+	ctx := serveETag(t, "GetV1User", fasthttp.MethodGet, nil)
+	assert.Empty(t, ctx.Response.Header.Peek(constant.HeaderETag))
+	ctx = serveETag(t, "GetV1Versioned", fasthttp.MethodGet, map[string]string{"If-None-Match": `"v3"`})
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+}
+
+func TestETagHead(t *testing.T) {
+	get := serveETag(t, "GetV1User", fasthttp.MethodGet, nil)
+	ctx := serveETag(t, "GetV1User", fasthttp.MethodHead, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.True(t, ctx.Response.SkipBody)
+	assert.Equal(t, string(get.Response.Header.Peek(constant.HeaderETag)), string(ctx.Response.Header.Peek(constant.HeaderETag)))
+
+	ctx = serveETag(t, "GetV1User", fasthttp.MethodHead,
+		map[string]string{"If-None-Match": string(get.Response.Header.Peek(constant.HeaderETag))})
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	ctx := newRequestCtx(fasthttp.MethodGet, "/", "", "")
+	ctx.Response.Header.Set(constant.HeaderLastModified, "Wed, 01 May 2024 08:00:00 GMT")
+	assert.False(t, NotModified(ctx))
+	ctx.Request.Header.Set(constant.HeaderIfModifiedSince, "Wed, 01 May 2024 08:00:00 GMT")
+	assert.True(t, NotModified(ctx))
+	ctx.Request.Header.Set(constant.HeaderIfModifiedSince, "Wed, 01 May 2024 07:59:59 GMT")
+	assert.False(t, NotModified(ctx))
+	// If-None-Match takes precedence
+	ctx.Request.Header.Set(constant.HeaderIfModifiedSince, "Wed, 01 May 2024 08:00:00 GMT")
+	ctx.Request.Header.Set(constant.HeaderIfNoneMatch, `"v1"`)
+	assert.False(t, NotModified(ctx))
+}
+
+func TestCheckPrecondition(t *testing.T) {
+	for _, tt := range []struct {
+		header map[string]string
+		status int
+	}{
+		{nil, fasthttp.StatusOK},
+		{map[string]string{"If-Match": `"v3"`}, fasthttp.StatusOK},
+		{map[string]string{"If-Match": `"v1", "v3"`}, fasthttp.StatusOK},
+		{map[string]string{"If-Match": "*"}, fasthttp.StatusOK},
+		{map[string]string{"If-Match": `"v2"`}, fasthttp.StatusPreconditionFailed},
+		{map[string]string{"If-Match": `W/"v3"`}, fasthttp.StatusPreconditionFailed},
+		{map[string]string{"If-None-Match": "*"}, fasthttp.StatusPreconditionFailed},
+		{map[string]string{"If-None-Match": `"v2"`}, fasthttp.StatusOK},
+	} {
+		ctx := serveETag(t, "PutV1User", fasthttp.MethodPut, tt.header)
+		assert.Equal(t, tt.status, ctx.Response.StatusCode(), tt.header)
+		if tt.status == fasthttp.StatusOK {
+			assert.Equal(t, `"v4"`, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+		}
+	}
+	// a missing resource matches no If-Match, even *
+	ctx := newRequestCtx(fasthttp.MethodDelete, "/", "", "")
+	ctx.Request.Header.Set(constant.HeaderIfMatch, "*")
+	assert.Error(t, CheckPrecondition(ctx, ""))
+	ctx.Request.Header.Del(constant.HeaderIfMatch)
+	ctx.Request.Header.Set(constant.HeaderIfNoneMatch, "*")
+	assert.NoError(t, CheckPrecondition(ctx, ""))
+}
@@ -5,8 +5,8 @@
 package handler
 
 import (
+	"errors"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
-	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
 	"github.com/valyala/fasthttp"
 )
 
@@ -20,18 +20,57 @@ func NotFoundHandler(ctx *fasthttp.RequestCtx) {
 	ctx.SetBodyString("404 Not Found")
 }
 
+// SuccessHandler writes data as it is as text/plain, whatever it holds,
+// a method returns a json.RawMessage to send json it encoded itself
 func SuccessHandler(ctx *fasthttp.RequestCtx, data string) {
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.Response.Header.SetServer("JetServer")
-	ctx.Response.Header.Set("Content-Type", constant.MIMEApplicationJSON)
+	ctx.SetContentType(constant.MIMETextPlainCharsetUTF8)
 	ctx.SetBodyString(data)
 }
 
+// RestSuccessHandler encodes data in the media type negotiated by the Accept header, see RegisterEncoder
 func RestSuccessHandler(ctx *fasthttp.RequestCtx, data any) {
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.Response.Header.SetServer("JetServer")
-	ctx.SetContentType(constant.MIMEApplicationJSON)
-	ctx.SetBodyString(utils.ObjToJsonStr(data))
+	if err := encodeResponse(ctx, data); err != nil {
+		failWithError(ctx, err)
+	}
+}
+
+// Responder is implemented by return values carrying the status, headers and cookies of the response
+// along with the body, like jet.Response[T]
+type Responder interface {
+	// ResponseBody returns the body, which is written like any other return value, nil for an empty body
+	ResponseBody() any
+	// ApplyResponse sets the status, headers and cookies after the body is written
+	ApplyResponse(resp *fasthttp.Response)
+}
+
+// ResponseWriter is implemented by return values writing the response by themselves, like jet.File streaming a file.
+// They skip the PostMethodExecuteHook, the encoders and the envelope.
+type ResponseWriter interface {
+	// WriteResponse writes the status, headers and body, an error is written like the errors returned by the method
+	WriteResponse(ctx *fasthttp.RequestCtx) error
+}
+
+// writeData writes a non-error return value of a handler method, an empty body for nil,
+// a string as text/plain and a []byte as application/octet-stream as they are, the Content-Type is picked
+// by the Go type only, and everything else in the media type negotiated by the Accept header
+func writeData(ctx *fasthttp.RequestCtx, data any) {
+	switch v := data.(type) {
+	case nil:
+		SuccessHandler(ctx, constant.EmptyString)
+	case string:
+		SuccessHandler(ctx, v)
+	case []byte:
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.Header.SetServer("JetServer")
+		ctx.SetContentType(constant.MIMEOctetStream)
+		ctx.SetBody(v)
+	default:
+		RestSuccessHandler(ctx, data)
+	}
 }
 
 func FailHandler(ctx *fasthttp.RequestCtx, data string) {
@@ -39,6 +78,60 @@ func FailHandler(ctx *fasthttp.RequestCtx, data string) {
 	ctx.SetBodyString(data)
 }
 
+// StatusCoder is implemented by errors carrying the HTTP status code of their response, like *constant.Error
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// BodyError is implemented by errors written as a body of their own, encoded in the negotiated media type
+// like a *constant.Error, as jet.ValidationError keeps the body Jet always wrote for invalid parameters
+type BodyError interface {
+	error
+	ErrorBody() any
+}
+
+// statusOf returns the status code of the first StatusCoder in the chain of err, else the status already set
+// on the response, like by a PreMethodExecuteHook, and 500 if it is still 200
+func statusOf(ctx *fasthttp.RequestCtx, err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		if code := sc.StatusCode(); code >= 100 && code <= 599 {
+			return code
+		}
+	}
+	if code := ctx.Response.StatusCode(); code != fasthttp.StatusOK {
+		return code
+	}
+	return fasthttp.StatusInternalServerError
+}
+
+// failWithError writes err with its status code by the ErrorRendererFunc, see statusOf and SetErrorRenderer
+func failWithError(ctx *fasthttp.RequestCtx, err error) {
+	ctx.SetStatusCode(statusOf(ctx, err))
+	ctx.Response.Header.SetServer("JetServer")
+	errorRenderer(ctx, err)
+}
+
+// FailErrorHandler writes err like the errors returned by the handler methods, for middlewares
+func FailErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	failWithError(ctx, err)
+}
+
+// renderError is the default ErrorRendererFunc, a BodyError or a *constant.Error in the chain of err is encoded
+// in the negotiated media type, any other error is written as text.
+func renderError(ctx *fasthttp.RequestCtx, err error) {
+	var be BodyError
+	if errors.As(err, &be) && encodeResponse(ctx, be.ErrorBody()) == nil {
+		return
+	}
+	var e *constant.Error
+	if errors.As(err, &e) && encodeResponse(ctx, e) == nil {
+		return
+	}
+	ctx.SetContentType(constant.MIMETextPlainCharsetUTF8)
+	ctx.SetBodyString(err.Error())
+}
+
 // FailServerInternalErrorHandler Internal Server Error
 func FailServerInternalErrorHandler(ctx *fasthttp.RequestCtx, data string) {
 	ctx.Response.Header.SetServer("JetServer")
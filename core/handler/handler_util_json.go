@@ -0,0 +1,175 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var typeOfJsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// checkJSON walks the json body along with the type it is decoded into,
+// and reports the path of the first violation of config, like $.user.name or $.items[2].
+func checkJSON(body []byte, typ reflect.Type, config *RouteConfig) error {
+	c := jsonChecker{dec: json.NewDecoder(bytes.NewReader(body)), config: config}
+	c.dec.UseNumber()
+	if err := c.value(typ, "$", 0); err != nil {
+		return err
+	}
+	if config.StrictJSON {
+		if _, err := c.dec.Token(); err != io.EOF {
+			return constant.NewError(constant.StatusBadRequest, "json: unexpected data after the top-level value")
+		}
+	}
+	return nil
+}
+
+type jsonChecker struct {
+	dec    *json.Decoder
+	config *RouteConfig
+}
+
+func (c *jsonChecker) value(typ reflect.Type, path string, depth int) error {
+	tok, err := c.dec.Token()
+	if err != nil {
+		return constant.NewError(constant.StatusBadRequest, fmt.Sprintf("json: invalid value at %s: %v", path, err))
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	if depth++; c.config.MaxJSONDepth > 0 && depth > c.config.MaxJSONDepth {
+		return constant.NewError(constant.StatusBadRequest,
+			fmt.Sprintf("json: %s is nested deeper than %d", path, c.config.MaxJSONDepth))
+	}
+	typ = jsonCheckedType(typ)
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if typ != nil && typ.Kind() == reflect.Struct {
+			fields = jsonFieldsOf(typ)
+		}
+		keys := make(map[string]struct{})
+		for c.dec.More() {
+			tok, err = c.dec.Token()
+			if err != nil {
+				return constant.NewError(constant.StatusBadRequest, fmt.Sprintf("json: invalid key at %s: %v", path, err))
+			}
+			key := tok.(string)
+			keyPath := path + "." + key
+			// the key of a struct is the field it sets, which json matches case-insensitively
+			field := key
+			var elem reflect.Type
+			switch {
+			case fields != nil:
+				var found bool
+				if field, elem, found = lookupJsonField(fields, key); !found && c.config.StrictJSON {
+					return constant.NewError(constant.StatusBadRequest, "json: unknown field "+keyPath)
+				}
+			case typ != nil && typ.Kind() == reflect.Map:
+				elem = typ.Elem()
+			}
+			if c.config.StrictJSON {
+				if _, dup := keys[field]; dup {
+					return constant.NewError(constant.StatusBadRequest, "json: duplicate key "+keyPath)
+				}
+				keys[field] = struct{}{}
+			}
+			if err = c.value(elem, keyPath, depth); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elem reflect.Type
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			elem = typ.Elem()
+		}
+		for i := 0; c.dec.More(); i++ {
+			if err = c.value(elem, path+"["+strconv.Itoa(i)+"]", depth); err != nil {
+				return err
+			}
+		}
+	}
+	// the closing delim
+	if _, err = c.dec.Token(); err != nil {
+		return constant.NewError(constant.StatusBadRequest, fmt.Sprintf("json: invalid value at %s: %v", path, err))
+	}
+	return nil
+}
+
+// jsonCheckedType dereferences typ, nil means the fields below are not checked,
+// like any or a type that decodes itself by json.Unmarshaler.
+func jsonCheckedType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ != nil && typ.Kind() == reflect.Struct && (isTypedParam(typ) || reflect.PtrTo(typ).Implements(typeOfOptional)) {
+		// param wrappers are transparent to json
+		return jsonCheckedType(typ.Field(0).Type)
+	}
+	if typ == nil || typ.Kind() == reflect.Interface || reflect.PtrTo(typ).Implements(typeOfJsonUnmarshaler) {
+		return nil
+	}
+	return typ
+}
+
+var jsonFieldsCache sync.Map // map[reflect.Type]map[string]reflect.Type
+
+// jsonFieldsOf returns the json names of the fields of a struct, including the promoted fields of embedded structs
+func jsonFieldsOf(typ reflect.Type) map[string]reflect.Type {
+	if fields, ok := jsonFieldsCache.Load(typ); ok {
+		return fields.(map[string]reflect.Type)
+	}
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if sf.Anonymous && name == "" {
+			if ft := indirectType(sf.Type); ft.Kind() == reflect.Struct {
+				for k, v := range jsonFieldsOf(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = sf.Type
+	}
+	jsonFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// lookupJsonField matches the key like encoding/json, an exact match first and then a case-insensitive one,
+// name is the json name of the field, the key itself if none matches
+func lookupJsonField(fields map[string]reflect.Type, key string) (name string, typ reflect.Type, ok bool) {
+	if typ, ok = fields[key]; ok {
+		return key, typ, true
+	}
+	for name, typ = range fields {
+		if strings.EqualFold(name, key) {
+			return name, typ, true
+		}
+	}
+	return key, nil, false
+}
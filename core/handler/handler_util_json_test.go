@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type jsonItem struct {
+	Id int `json:"id"`
+}
+
+type jsonReq struct {
+	Name  string         `json:"name"`
+	Items []jsonItem     `json:"items"`
+	Extra map[string]any `json:"extra"`
+}
+
+func TestCheckJSON(t *testing.T) {
+	typ := reflect.TypeOf(&jsonReq{})
+	strict := &RouteConfig{StrictJSON: true, MaxJSONDepth: 3}
+	testData := []struct {
+		body    string
+		message string
+	}{
+		{`{"name":"jet","items":[{"id":1}],"extra":{"a":1}}`, ""},
+		{`{"NAME":"jet"}`, ""},
+		{`{"name":"jet","nmae":"jet"}`, "json: unknown field $.nmae"},
+		{`{"items":[{"id":1},{"id":2,"idd":3}]}`, "json: unknown field $.items[1].idd"},
+		{`{"name":"jet","name":"jet"}`, "json: duplicate key $.name"},
+		{`{"name":"jet","NAME":"jet"}`, "json: duplicate key $.NAME"},
+		{`{"extra":{"a":1,"A":2}}`, ""},
+		{`{"name":"jet"} {}`, "json: unexpected data after the top-level value"},
+		{`{"extra":{"a":{"b":{}}}}`, "json: $.extra.a.b is nested deeper than 3"},
+	}
+	for _, td := range testData {
+		err := checkJSON([]byte(td.body), typ, strict)
+		if td.message == "" {
+			assert.NoError(t, err, td.body)
+			continue
+		}
+		assert.EqualError(t, err, td.message, td.body)
+		assert.Equal(t, constant.StatusBadRequest, err.(*constant.Error).Code)
+	}
+	assert.NoError(t, checkJSON([]byte(`{"nmae":"jet","nmae":1}`), typ, &RouteConfig{}))
+}
+
+func (c *creatorController) PostStrict(req *jsonReq) error {
+	return nil
+}
+
+func TestHandler_ServeHTTPWithRouteConfig(t *testing.T) {
+	ConfigureRoute((*creatorController).PostStrict, RouteConfig{StrictJSON: true, MaxBodySize: 32})
+	rcvr := reflect.ValueOf(&creatorController{})
+	method, _ := rcvr.Type().MethodByName("PostStrict")
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+
+	ctx := newRequestCtx(fasthttp.MethodPost, "/strict", "application/json", `{"name":"jet"}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = newRequestCtx(fasthttp.MethodPost, "/strict", "application/json", `{"nmae":"jet"}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "$.nmae")
+
+	ctx = newRequestCtx(fasthttp.MethodPost, "/strict", "application/json", `{"name":"a very long name of jet"}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusRequestEntityTooLarge, ctx.Response.StatusCode())
+}
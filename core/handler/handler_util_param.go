@@ -0,0 +1,157 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var unusedTyped *param.Typed
+var unusedOptional *param.OptionalValue
+var typeOfTyped = reflect.TypeOf(unusedTyped).Elem()
+var typeOfOptional = reflect.TypeOf(unusedOptional).Elem()
+
+// isTypedParam reports whether t is a typed parameter wrapper like param.Query[T] or a pointer to it
+func isTypedParam(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return t.Implements(typeOfTyped)
+	}
+	return reflect.PtrTo(t).Implements(typeOfTyped)
+}
+
+// typedSourceOf returns the source of the typed parameter wrapper t
+func typedSourceOf(t reflect.Type) param.Source {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface().(param.Typed).ParamSource()
+}
+
+// bindTyped binds a typed parameter wrapper from its source,
+// name is the query or header name, index is the position of the path arg.
+func bindTyped(ctx *fasthttp.RequestCtx, typed param.Typed, name string, index int, args []string, timeFormat string, config *RouteConfig) (err error) {
+	var (
+		source = typed.ParamSource()
+		v      = typed.ParamValue()
+	)
+	switch source {
+	case param.SourceQuery:
+		if peeks := ctx.QueryArgs().PeekMulti(name); len(peeks) > 0 {
+			err = strconvParseValues(v, parseMultiPeek(peeks), timeFormat)
+		}
+	case param.SourcePath:
+		if index < len(args) {
+			err = strconvParseValueFormat(v, args[index], timeFormat)
+		}
+	case param.SourceHeader:
+		if peek := ctx.Request.Header.Peek(name); peek != nil {
+			err = strconvParseValues(v, []string{string(peek)}, timeFormat)
+		}
+	case param.SourceBody:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(ctx.Request.Body()))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(append([]byte(nil), ctx.Request.Body()...))
+		default:
+			err = parseReqDefault(ctx, v.Addr(), args, config)
+		}
+	}
+	if err != nil {
+		if source == param.SourcePath {
+			name = strconv.Itoa(index)
+		}
+		return constant.NewError(constant.StatusBadRequest, fmt.Sprintf("invalid %v parameter [%s]: %v", source, name, err))
+	}
+	return
+}
+
+// bindTypedFields binds the typed parameter wrappers declared as fields of the struct v points to,
+// the name comes from the struct tag of the source, `query` falls back to `form`.
+func bindTypedFields(ctx *fasthttp.RequestCtx, v reflect.Value, args []string, config *RouteConfig) (err error) {
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		sfv := v.Field(i)
+		if !isTypedParam(sf.Type) {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				if err = bindTypedFields(ctx, sfv.Addr(), args, config); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		if sf.Type.Kind() == reflect.Ptr {
+			if sfv.IsNil() {
+				sfv.Set(reflect.New(sf.Type.Elem()))
+			}
+		} else {
+			sfv = sfv.Addr()
+		}
+		typed := sfv.Interface().(param.Typed)
+		name, index := typedFieldName(sf, typed.ParamSource())
+		if err = bindTyped(ctx, typed, name, index, args, sf.Tag.Get("time_format"), config); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func typedFieldName(sf reflect.StructField, source param.Source) (name string, index int) {
+	var tag string
+	switch source {
+	case param.SourceQuery:
+		if tag = sf.Tag.Get("query"); tag == "" {
+			tag = sf.Tag.Get("form")
+		}
+	case param.SourceHeader:
+		tag = sf.Tag.Get("header")
+	case param.SourcePath:
+		index, _ = strconv.Atoi(strings.Split(sf.Tag.Get("path"), ",")[0])
+		return
+	}
+	if name = strings.Split(tag, ",")[0]; name == "" {
+		name = sf.Name
+	}
+	return
+}
+
+// strconvParseValues parses values into v, a slice takes every value and others take the first,
+// see isMultiValue
+func strconvParseValues(v reflect.Value, values []string, timeFormat string) (err error) {
+	if !isMultiValue(v.Type()) {
+		return strconvParseValueFormat(v, values[0], timeFormat)
+	}
+	n := len(values)
+	slice := reflect.MakeSlice(v.Type(), n, n)
+	for i := 0; i < n; i++ {
+		if err = strconvParseValueFormat(slice.Index(i), values[i], timeFormat); err != nil {
+			return
+		}
+	}
+	v.Set(slice)
+	return
+}
+
+// optionalOf returns v as param.OptionalValue if v is an addressable param.Optional[T]
+func optionalOf(v reflect.Value) (param.OptionalValue, bool) {
+	if v.Kind() != reflect.Struct || !v.CanAddr() || !reflect.PtrTo(v.Type()).Implements(typeOfOptional) {
+		return nil, false
+	}
+	return v.Addr().Interface().(param.OptionalValue), true
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type typedController struct{}
+
+func (c *typedController) GetV1Usage0Week(id param.Path[int64], page param.Query[int], ids param.Query[[]int], token *param.Header[string]) (error, map[string]any) {
+	return nil, map[string]any{"id": id.Get(), "page": page.Get(), "ids": ids.Get(), "token": token.Get()}
+}
+
+type typedFieldsReq struct {
+	Name  string               `form:"name"`
+	Size  param.Query[int]     `query:"size"`
+	Token param.Header[string] `header:"X-Token"`
+	Id    param.Path[int]      `path:"0"`
+}
+
+func (c *typedController) GetV1Fields0(req *typedFieldsReq) (error, *typedFieldsReq) {
+	return nil, req
+}
+
+func (c *typedController) PostV1Raw(body param.Body[string]) (string, error) {
+	return body.Get(), nil
+}
+
+func newTypedHandler(t *testing.T, name string) IHandler {
+	rcvr := reflect.ValueOf(&typedController{})
+	method, _ := rcvr.Type().MethodByName(name)
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+	return h
+}
+
+func TestTypedParams(t *testing.T) {
+	_, err := parseMethodParams(func() *reflect.Method {
+		m, _ := reflect.TypeOf(&typedController{}).MethodByName("GetV1Usage0Week")
+		return &m
+	}())
+	assert.ErrorIs(t, err, ErrMethodSignature, "query params without names must be rejected")
+
+	param.Names((*typedController).GetV1Usage0Week, "page", "ids", "Authorization")
+	h := newTypedHandler(t, "GetV1Usage0Week")
+
+	ctx := newRequestCtx(fasthttp.MethodGet, "/v1/usage/7/week?page=2&ids=1&ids=2", "", "")
+	ctx.Request.Header.Set("Authorization", "jet")
+	h.ServeHTTP(ctx, []string{"7"})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"id":7,"page":2,"ids":[1,2],"token":"jet"}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/usage/7/week?page=two", "", "")
+	h.ServeHTTP(ctx, []string{"7"})
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "invalid query parameter [page]")
+}
+
+func TestTypedParamFields(t *testing.T) {
+	h := newTypedHandler(t, "GetV1Fields0")
+	ctx := newRequestCtx(fasthttp.MethodGet, "/v1/fields/3?name=jet&size=10", "", "")
+	ctx.Request.Header.Set("X-Token", "abc")
+	h.ServeHTTP(ctx, []string{"3"})
+	assert.JSONEq(t, `{"Name":"jet","Size":10,"Token":"abc","Id":3}`, string(ctx.Response.Body()))
+
+	h = newTypedHandler(t, "PostV1Raw")
+	ctx = newRequestCtx(fasthttp.MethodPost, "/v1/raw", "text/plain", "hello jet")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, "hello jet", string(ctx.Response.Body()))
+}
+
+type optionalReq struct {
+	Nickname param.Optional[string] `json:"nickname" form:"nickname"`
+	Age      param.Optional[int]    `json:"age" form:"age" validate:"omitempty,min=1"`
+	Level    param.Optional[int]    `json:"level" form:"level" default:"3"`
+}
+
+func (c *typedController) PatchV1User(req *optionalReq) (error, map[string]any) {
+	state := func(present, null bool, v any) any {
+		switch {
+		case !present:
+			return "absent"
+		case null:
+			return "null"
+		}
+		return v
+	}
+	return utils.Struct(req), map[string]any{
+		"nickname": state(req.Nickname.Present, req.Nickname.Null, req.Nickname.Value),
+		"age":      state(req.Age.Present, req.Age.Null, req.Age.Value),
+		"level":    req.Level.OrElse(-1),
+	}
+}
+
+func (c *typedController) GetV1Optional(age param.Query[param.Optional[int]]) (error, map[string]any) {
+	v, ok := age.Get().Get()
+	return nil, map[string]any{"age": v, "ok": ok, "present": age.Get().Present}
+}
+
+func TestOptional(t *testing.T) {
+	h := newTypedHandler(t, "PatchV1User")
+	for body, want := range map[string]string{
+		`{}`:                              `{"nickname":"absent","age":"absent","level":3}`,
+		`{"nickname":null,"age":0}`:       `{"nickname":"null","age":0,"level":3}`,
+		`{"nickname":"","age":null}`:      `{"nickname":"","age":"null","level":3}`,
+		`{"nickname":"jet","level":null}`: `{"nickname":"jet","age":"absent","level":-1}`,
+	} {
+		ctx := newRequestCtx(fasthttp.MethodPatch, "/v1/user", "application/json", body)
+		h.ServeHTTP(ctx, nil)
+		assert.JSONEq(t, want, string(ctx.Response.Body()), body)
+	}
+
+	ctx := newRequestCtx(fasthttp.MethodPatch, "/v1/user?nickname=&age=", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"nickname":"","age":"null","level":3}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/user?age=12", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"nickname":"absent","age":12,"level":3}`, string(ctx.Response.Body()))
+
+	// validation runs on the wrapped value
+	assert.Error(t, utils.Struct(&optionalReq{Age: param.Some(-1)}))
+	assert.NoError(t, utils.Struct(&optionalReq{Age: param.Null[int]()}))
+	assert.NoError(t, utils.Struct(&optionalReq{Age: param.Some(18)}))
+
+	param.Names((*typedController).GetV1Optional, "age")
+	h = newTypedHandler(t, "GetV1Optional")
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/optional?age=7", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"age":7,"ok":true,"present":true}`, string(ctx.Response.Body()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/optional?age=", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"age":0,"ok":false,"present":true}`, string(ctx.Response.Body()))
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/optional", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"age":0,"ok":false,"present":false}`, string(ctx.Response.Body()))
+}
+
+func (c *typedController) PatchV1Doc(patch *param.Patch) (error, map[string]any) {
+	doc := map[string]any{"name": "jet", "tags": []string{"a"}}
+	return patch.ApplyTo(&doc), doc
+}
+
+type patchQuery struct {
+	DryRun bool `json:"dry_run" form:"dry_run"`
+}
+
+func (c *typedController) PatchV1Item0(id param.Path[int64], q *patchQuery, patch param.Patch) (error, map[string]any) {
+	doc := map[string]any{"id": id.Get(), "name": "jet"}
+	err := patch.ApplyTo(&doc)
+	doc["dry_run"] = q.DryRun
+	return err, doc
+}
+
+func TestPatchBinding(t *testing.T) {
+	h := newTypedHandler(t, "PatchV1Doc")
+	ctx := newRequestCtx(fasthttp.MethodPatch, "/v1/doc", constant.MIMEApplicationJSONPatch, `[{"op":"add","path":"/tags/-","value":"b"}]`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"name":"jet","tags":["a","b"]}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/doc", constant.MIMEApplicationMergePatch, `{"name":null}`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"tags":["a"]}`, string(ctx.Response.Body()))
+
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/doc", constant.MIMEApplicationJSON, `{"name":null}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusUnsupportedMediaType, ctx.Response.StatusCode())
+
+	// a merge patch binds like json, a json patch needs param.Patch
+	h = newTypedHandler(t, "PatchV1User")
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/user", constant.MIMEApplicationMergePatch, `{"age":null}`)
+	h.ServeHTTP(ctx, nil)
+	assert.JSONEq(t, `{"nickname":"absent","age":"null","level":3}`, string(ctx.Response.Body()))
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/user", constant.MIMEApplicationJSONPatch, `[]`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusUnsupportedMediaType, ctx.Response.StatusCode())
+
+	// the other parameters of a method with param.Patch are bound from the path and query
+	h = newTypedHandler(t, "PatchV1Item0")
+	ctx = newRequestCtx(fasthttp.MethodPatch, "/v1/item/7?dry_run=true", constant.MIMEApplicationJSONPatch,
+		`[{"op":"replace","path":"/name","value":"rocket"}]`)
+	h.ServeHTTP(ctx, []string{"7"})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"id":7,"name":"rocket","dry_run":true}`, string(ctx.Response.Body()))
+}
@@ -0,0 +1,83 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// ErrorRendererFunc writes the body of an error response, the status code is already set
+type ErrorRendererFunc = func(ctx *fasthttp.RequestCtx, err error)
+
+var errorRenderer ErrorRendererFunc = renderError
+
+// reqIdKey is the user value of the request id of the request context, see xlog.Logger.ReqId
+const reqIdKey = "jet_req_id"
+
+// SetErrorRenderer sets how errors are written, like
+//
+//	handler.SetErrorRenderer(handler.RenderProblemDetails)
+//
+// nil restores the default, which encodes a *constant.Error in the negotiated media type and writes others as text.
+// It must be called before the server starts.
+func SetErrorRenderer(f ErrorRendererFunc) {
+	if f == nil {
+		f = renderError
+	}
+	errorRenderer = f
+}
+
+// ProblemDetails is the application/problem+json body of RFC 7807
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// extension members
+	RequestId string             `json:"request_id,omitempty"`
+	Code      string             `json:"code,omitempty"` // constant.Error.Reason
+	Details   any                `json:"details,omitempty"`
+	Errors    []utils.FieldError `json:"errors,omitempty"` // the fields failing validation
+}
+
+// ProblemDetailsOf builds the ProblemDetails of err for the request.
+// The detail is the message of a *constant.Error or a StatusCoder, other errors may leak internals and have none.
+func ProblemDetailsOf(ctx *fasthttp.RequestCtx, err error) *ProblemDetails {
+	status := statusOf(ctx, err)
+	p := &ProblemDetails{
+		Type:      "about:blank",
+		Title:     constant.StatusMessage(status),
+		Status:    status,
+		Instance:  string(ctx.Path()),
+		RequestId: RequestIdOf(ctx),
+		Errors:    utils.FieldErrors(err),
+	}
+	var e *constant.Error
+	var sc StatusCoder
+	if errors.As(err, &e) {
+		p.Detail, p.Code = e.Message, e.Reason
+		if p.Errors == nil {
+			p.Details = e.Details
+		}
+	} else if errors.As(err, &sc) {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// RenderProblemDetails is an ErrorRendererFunc writing the ProblemDetails of err as application/problem+json
+func RenderProblemDetails(ctx *fasthttp.RequestCtx, err error) {
+	body, encodeErr := utils.ObjToByte(ProblemDetailsOf(ctx, err))
+	if encodeErr != nil {
+		renderError(ctx, err)
+		return
+	}
+	ctx.SetContentType(constant.MIMEApplicationProblem)
+	ctx.SetBody(body)
+}
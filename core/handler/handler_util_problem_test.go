@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type problemReq struct {
+	Name    string `json:"name" validate:"required"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+type problemController struct{}
+
+func (c *problemController) PostV1User(req *problemReq) error {
+	if err := utils.Struct(req); err != nil {
+		return constant.ErrUnprocessableEntity.WithReason("INVALID_USER").Wrap(err)
+	}
+	return nil
+}
+
+func (c *problemController) GetV1Plain() error {
+	return errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func TestRenderProblemDetails(t *testing.T) {
+	SetErrorRenderer(RenderProblemDetails)
+	defer SetErrorRenderer(nil)
+
+	rcvr := reflect.ValueOf(&problemController{})
+	method, _ := rcvr.Type().MethodByName("PostV1User")
+	h, err := HandlerCreator{}.New(&rcvr, &method)
+	assert.NoError(t, err)
+	ctx := newRequestCtx(fasthttp.MethodPost, "/v1/user", constant.MIMEApplicationJSON, `{"address":{}}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusUnprocessableEntity, ctx.Response.StatusCode())
+	assert.Equal(t, constant.MIMEApplicationProblem, string(ctx.Response.Header.ContentType()))
+	var p ProblemDetails
+	assert.NoError(t, utils.ByteToObj(ctx.Response.Body(), &p))
+	assert.NotEmpty(t, p.RequestId)
+	p.RequestId = ""
+	assert.Equal(t, ProblemDetails{
+		Type:     "about:blank",
+		Title:    "Unprocessable Entity",
+		Status:   fasthttp.StatusUnprocessableEntity,
+		Detail:   "Unprocessable Entity",
+		Instance: "/v1/user",
+		Code:     "INVALID_USER",
+		Errors: []utils.FieldError{
+			{Field: "name", Rule: "required", Message: "name is required"},
+			{Field: "address.city", Rule: "required", Message: "address.city is required"},
+		},
+	}, p)
+
+	method, _ = rcvr.Type().MethodByName("GetV1Plain")
+	h, _ = HandlerCreator{}.New(&rcvr, &method)
+	ctx = newRequestCtx(fasthttp.MethodGet, "/v1/plain", "", "")
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.NotContains(t, string(ctx.Response.Body()), "connection refused", "plain errors have no detail")
+	assert.Contains(t, string(ctx.Response.Body()), `"title":"Internal Server Error"`)
+}
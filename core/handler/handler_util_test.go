@@ -1,8 +1,16 @@
 package handler
 
 import (
+	"encoding/json"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestPrefixOf(t *testing.T) {
@@ -23,3 +31,141 @@ func TestPrefixOf(t *testing.T) {
 		assert.Equal(t, td.ok, ok, "For input '%s', expected ok '%t', got '%t'", td.input, td.ok, ok)
 	}
 }
+
+type money int64
+
+func TestStrconvParseValue(t *testing.T) {
+	RegisterConverter(func(str string) (money, error) {
+		yuan, err := strconv.ParseFloat(str, 64)
+		return money(yuan * 100), err
+	})
+	var req struct {
+		Time     time.Time     `form:"time"`
+		Day      time.Time     `form:"day" time_format:"2006-01-02"`
+		Unix     time.Time     `form:"unix" time_format:"unix"`
+		Timeout  time.Duration `form:"timeout"`
+		IP       net.IP        `form:"ip"`
+		Big      *big.Int      `form:"big"`
+		Number   json.Number   `form:"number"`
+		Price    money         `form:"price"`
+		Birthday *time.Time    `form:"birthday" time_format:"2006-01-02"`
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/?time=2023-11-01T08:00:00Z&day=2023-11-01&unix=1698796800&timeout=1m30s" +
+		"&ip=127.0.0.1&big=123456789012345678901234567890&number=1.5&price=9.99&birthday=2000-01-02")
+	assert.NoError(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+	assert.True(t, req.Time.Equal(time.Date(2023, 11, 1, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2023-11-01", req.Day.Format("2006-01-02"))
+	assert.Equal(t, int64(1698796800), req.Unix.Unix())
+	assert.Equal(t, 90*time.Second, req.Timeout)
+	assert.Equal(t, "127.0.0.1", req.IP.String())
+	assert.Equal(t, "123456789012345678901234567890", req.Big.String())
+	assert.Equal(t, json.Number("1.5"), req.Number)
+	assert.Equal(t, money(999), req.Price)
+	assert.Equal(t, 2000, req.Birthday.Year())
+
+	ctx.Request.SetRequestURI("/?ip=localhost")
+	assert.Error(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+}
+
+func TestSplitKey(t *testing.T) {
+	testData := []struct {
+		input    string
+		expected []string
+	}{
+		{"page", []string{"page"}},
+		{"page.size", []string{"page", "size"}},
+		{"filter[status]", []string{"filter", "status"}},
+		{"ids[]", []string{"ids", ""}},
+		{"users[0][name]", []string{"users", "0", "name"}},
+		{"users[0].name", []string{"users", "0", "name"}},
+	}
+	for _, td := range testData {
+		assert.Equal(t, td.expected, splitKey(td.input), "For input '%s'", td.input)
+	}
+}
+
+func TestParseValueNested(t *testing.T) {
+	type page struct {
+		Size int `form:"size"`
+		No   int `form:"no"`
+	}
+	type user struct {
+		Name string `form:"name"`
+	}
+	var req struct {
+		Page       *page             `form:"page"`
+		Filter     map[string]string `form:"filter"`
+		Ids        []int             `form:"ids"`
+		Tags       []string          `form:"tags"`
+		Users      []user            `form:"users"`
+		Extra      map[string]any    `form:"extra"`
+		Keyword    string            `form:"keyword,has"`
+		HasKeyword bool
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/?page.size=10&page[no]=2&filter[status]=active&ids[]=1&ids[]=2&tags=a&tags=b" +
+		"&users[1][name]=bob&users[0].name=alice&extra[a][b]=c&keyword=jet")
+	assert.NoError(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+	assert.Equal(t, &page{Size: 10, No: 2}, req.Page)
+	assert.Equal(t, map[string]string{"status": "active"}, req.Filter)
+	assert.Equal(t, []int{1, 2}, req.Ids)
+	assert.Equal(t, []string{"a", "b"}, req.Tags)
+	assert.Equal(t, []user{{"alice"}, {"bob"}}, req.Users)
+	assert.Equal(t, map[string]any{"a": map[string]any{"b": "c"}}, req.Extra)
+	assert.True(t, req.HasKeyword)
+
+	defer SetBindConfig(bindConfig)
+	SetBindConfig(BindConfig{ArrayFormat: ArrayFormatComma, MaxDepth: 2, MaxElements: 3})
+	ctx.Request.SetRequestURI("/?ids=1,2,3")
+	assert.NoError(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+	assert.Equal(t, []int{1, 2, 3}, req.Ids)
+
+	ctx.Request.SetRequestURI("/?ids=1,2,3,4")
+	assert.Error(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+	ctx.Request.SetRequestURI("/?users[0][name][x]=1")
+	assert.Error(t, parseValue(reflect.ValueOf(&req), ctx, "form"))
+}
+
+func TestParseFormParity(t *testing.T) {
+	type embedded struct {
+		Page int `form:"page"`
+	}
+	type formReq struct {
+		embedded
+		Name    string            `form:"name,has"`
+		Ids     []int             `form:"ids"`
+		Filter  map[string]string `form:"filter"`
+		Comment string            `form:"comment"`
+		HasName bool
+	}
+	const query = "page=2&name=jet&ids=1&ids=2&filter[status]=active&comment="
+
+	var byQuery, byForm, byMultipart formReq
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/?" + query)
+	assert.NoError(t, parseValue(reflect.ValueOf(&byQuery), ctx, "form"))
+
+	ctx = new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/")
+	ctx.Request.Header.SetContentType(constant.MIMEApplicationForm)
+	ctx.Request.SetBodyString(query)
+	assert.NoError(t, parseForm(reflect.ValueOf(&byForm), ctx))
+	assert.Equal(t, byQuery, byForm)
+
+	ctx = new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/")
+	ctx.Request.Header.SetContentType(constant.MIMEMultipartForm + "; boundary=jet")
+	ctx.Request.SetBodyString("--jet\r\nContent-Disposition: form-data; name=\"page\"\r\n\r\n2\r\n" +
+		"--jet\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\njet\r\n" +
+		"--jet\r\nContent-Disposition: form-data; name=\"ids\"\r\n\r\n1\r\n" +
+		"--jet\r\nContent-Disposition: form-data; name=\"ids\"\r\n\r\n2\r\n" +
+		"--jet\r\nContent-Disposition: form-data; name=\"filter[status]\"\r\n\r\nactive\r\n" +
+		"--jet\r\nContent-Disposition: form-data; name=\"comment\"\r\n\r\n\r\n--jet--\r\n")
+	assert.NoError(t, parseForm(reflect.ValueOf(&byMultipart), ctx))
+	assert.Equal(t, byQuery, byMultipart)
+
+	assert.Equal(t, 2, byForm.Page)
+	assert.Equal(t, []int{1, 2}, byForm.Ids)
+	assert.True(t, byForm.HasName)
+}
@@ -0,0 +1,294 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package hub
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what happens to a message published to a subscription whose buffer is full
+type Policy int
+
+const (
+	DropOldest Policy = iota // drop the oldest buffered message to make room, the default
+	DropNewest               // drop the published message
+	Disconnect               // close the subscription with ErrSlowConsumer
+)
+
+const defaultBufferSize = 64
+
+// ErrSlowConsumer is the error of a subscription closed by the Disconnect policy
+var ErrSlowConsumer = errors.New("hub: subscriber is too slow")
+
+// Config configures a Hub
+type Config struct {
+	BufferSize int // the messages buffered for each subscription, 64 if it is 0
+	Policy     Policy
+}
+
+// Message is a message published to a topic
+type Message struct {
+	Topic string
+	Data  any
+}
+
+// Stats is a snapshot of the metrics of a Hub
+type Stats struct {
+	Topics        int
+	Subscriptions int
+	Published     uint64 // the calls of Publish
+	Delivered     uint64 // the messages buffered for subscriptions
+	Dropped       uint64 // the messages dropped by DropOldest and DropNewest
+	Disconnected  uint64 // the subscriptions closed by Disconnect
+}
+
+// Hub fans messages out to the subscriptions of named topics, like the rooms of a chat.
+// It is provided to the controllers like any other service, like
+//
+//	jet.Provide(hub.New)
+//
+//	func (c *ChatController) GetV1Room(h *hub.Hub, room param.Query[string]) jet.SSE {
+//		return jet.NewSSE(func(stream *jet.EventStream) error {
+//			sub := h.Subscribe(stream.Context(), room.Get())
+//			for msg := range sub.C() {
+//				if err := stream.Send(jet.Event{Data: msg.Data}); err != nil {
+//					return err
+//				}
+//			}
+//			return sub.Err()
+//		})
+//	}
+type Hub struct {
+	config        Config
+	lock          sync.RWMutex
+	topics        map[string]map[*Subscription]struct{}
+	subscriptions map[*Subscription]struct{}
+
+	published, delivered, dropped, disconnected uint64
+}
+
+// New returns a Hub of the default Config
+func New() *Hub {
+	return NewWith(Config{})
+}
+
+// NewWith returns a Hub of the config
+func NewWith(config Config) *Hub {
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+	return &Hub{
+		config:        config,
+		topics:        make(map[string]map[*Subscription]struct{}),
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a subscription of the topics, which is closed once ctx is done,
+// like the context of an event stream or a WebSocket connection.
+func (h *Hub) Subscribe(ctx context.Context, topics ...string) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		topics: make(map[string]struct{}),
+		ch:     make(chan Message, h.config.BufferSize),
+		done:   make(chan struct{}),
+	}
+	h.lock.Lock()
+	h.subscriptions[s] = struct{}{}
+	for _, topic := range topics {
+		h.join(s, topic)
+	}
+	h.lock.Unlock()
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				s.Close()
+			case <-s.done:
+			}
+		}()
+	}
+	return s
+}
+
+// Publish sends the data to every subscription of the topic and returns how many of them buffered it
+func (h *Hub) Publish(topic string, data any) (delivered int) {
+	atomic.AddUint64(&h.published, 1)
+	msg := Message{Topic: topic, Data: data}
+	var slow []*Subscription
+	h.lock.RLock()
+	for s := range h.topics[topic] {
+		if s.offer(msg, h.config.Policy) {
+			delivered++
+		} else if h.config.Policy == Disconnect {
+			slow = append(slow, s)
+		}
+	}
+	h.lock.RUnlock()
+	for _, s := range slow {
+		if s.close(ErrSlowConsumer) {
+			atomic.AddUint64(&h.disconnected, 1)
+		}
+	}
+	atomic.AddUint64(&h.delivered, uint64(delivered))
+	return
+}
+
+// Topics returns the topics having subscriptions in order
+func (h *Hub) Topics() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	topics := make([]string, 0, len(h.topics))
+	for topic := range h.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Subscribers returns the number of subscriptions of the topic
+func (h *Hub) Subscribers(topic string) int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Stats returns the metrics of the hub
+func (h *Hub) Stats() Stats {
+	h.lock.RLock()
+	stats := Stats{Topics: len(h.topics), Subscriptions: len(h.subscriptions)}
+	h.lock.RUnlock()
+	stats.Published = atomic.LoadUint64(&h.published)
+	stats.Delivered = atomic.LoadUint64(&h.delivered)
+	stats.Dropped = atomic.LoadUint64(&h.dropped)
+	stats.Disconnected = atomic.LoadUint64(&h.disconnected)
+	return stats
+}
+
+// join and leave must be called with the write lock held
+func (h *Hub) join(s *Subscription, topic string) {
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		h.topics[topic] = subs
+	}
+	subs[s] = struct{}{}
+	s.topics[topic] = struct{}{}
+}
+
+func (h *Hub) leave(s *Subscription, topic string) {
+	if subs, ok := h.topics[topic]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	delete(s.topics, topic)
+}
+
+// Subscription receives the messages of the topics it joined until it is closed
+type Subscription struct {
+	hub    *Hub
+	topics map[string]struct{} // guarded by the lock of the hub
+	ch     chan Message
+	// sendLock makes dropping the oldest message and buffering the new one atomic
+	sendLock sync.Mutex
+	done     chan struct{}
+	closed   bool // guarded by the lock of the hub
+	err      error
+}
+
+// C returns the channel of the messages, which is closed with the subscription
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Done returns a channel closed with the subscription
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns ErrSlowConsumer if the subscription is closed by the Disconnect policy, nil otherwise
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Join subscribes to one more topic
+func (s *Subscription) Join(topic string) {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+	if !s.closed {
+		s.hub.join(s, topic)
+	}
+}
+
+// Leave unsubscribes from the topic
+func (s *Subscription) Leave(topic string) {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+	s.hub.leave(s, topic)
+}
+
+// Close unsubscribes from every topic and closes the channel of the messages
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+func (s *Subscription) close(err error) bool {
+	h := s.hub
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closed, s.err = true, err
+	for topic := range s.topics {
+		h.leave(s, topic)
+	}
+	delete(h.subscriptions, s)
+	// no message is offered once the write lock is held, so closing the channel is safe
+	close(s.ch)
+	close(s.done)
+	return true
+}
+
+// offer buffers the message without blocking, false if it is dropped by a full buffer.
+// It is called with the read lock of the hub held.
+func (s *Subscription) offer(msg Message, policy Policy) bool {
+	select {
+	case s.ch <- msg:
+		return true
+	default:
+	}
+	if policy != DropOldest {
+		if policy == DropNewest {
+			atomic.AddUint64(&s.hub.dropped, 1)
+		}
+		return false
+	}
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+	for {
+		select {
+		case s.ch <- msg:
+			return true
+		default:
+		}
+		select {
+		case <-s.ch:
+			atomic.AddUint64(&s.hub.dropped, 1)
+		default:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package hub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func receive(s *Subscription) (data []any) {
+	for {
+		select {
+		case msg, ok := <-s.C():
+			if !ok {
+				return
+			}
+			data = append(data, msg.Data)
+		default:
+			return
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := New()
+	a := h.Subscribe(context.Background(), "room1", "room2")
+	b := h.Subscribe(context.Background(), "room1")
+	assert.Equal(t, []string{"room1", "room2"}, h.Topics())
+	assert.Equal(t, 2, h.Subscribers("room1"))
+
+	assert.Equal(t, 2, h.Publish("room1", "hi"))
+	assert.Equal(t, 1, h.Publish("room2", "only a"))
+	assert.Equal(t, 0, h.Publish("room3", "nobody"))
+	assert.Equal(t, []any{"hi", "only a"}, receive(a))
+	assert.Equal(t, []any{"hi"}, receive(b))
+
+	b.Join("room2")
+	a.Leave("room2")
+	h.Publish("room2", "only b")
+	assert.Empty(t, receive(a))
+	assert.Equal(t, []any{"only b"}, receive(b))
+
+	a.Close()
+	a.Close()
+	_, ok := <-a.C()
+	assert.False(t, ok)
+	assert.NoError(t, a.Err())
+	assert.Equal(t, Stats{Topics: 2, Subscriptions: 1, Published: 4, Delivered: 4}, h.Stats())
+}
+
+func TestHubPolicy(t *testing.T) {
+	h := NewWith(Config{BufferSize: 2})
+	s := h.Subscribe(context.Background(), "t")
+	for i := 0; i < 4; i++ {
+		h.Publish("t", i)
+	}
+	assert.Equal(t, []any{2, 3}, receive(s))
+	assert.Equal(t, uint64(2), h.Stats().Dropped)
+
+	h = NewWith(Config{BufferSize: 2, Policy: DropNewest})
+	s = h.Subscribe(context.Background(), "t")
+	for i := 0; i < 4; i++ {
+		h.Publish("t", i)
+	}
+	assert.Equal(t, []any{0, 1}, receive(s))
+	assert.Equal(t, uint64(2), h.Stats().Dropped)
+
+	h = NewWith(Config{BufferSize: 2, Policy: Disconnect})
+	s = h.Subscribe(context.Background(), "t")
+	for i := 0; i < 4; i++ {
+		h.Publish("t", i)
+	}
+	<-s.Done()
+	assert.Equal(t, ErrSlowConsumer, s.Err())
+	assert.Equal(t, []any{0, 1}, receive(s))
+	assert.Equal(t, Stats{Published: 4, Delivered: 2, Disconnected: 1}, h.Stats())
+}
+
+func TestHubContext(t *testing.T) {
+	h := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := h.Subscribe(ctx, "t")
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the subscription is not closed with its context")
+	}
+	assert.Equal(t, 0, h.Subscribers("t"))
+	assert.Empty(t, h.Topics())
+}
@@ -4,10 +4,26 @@
 
 package inject
 
-import "go.uber.org/dig"
+import (
+	"go.uber.org/dig"
+	"reflect"
+	"sync"
+)
 
 var container = dig.New()
 
+var (
+	// providedTypes records every type produced by constructors passed to Provide,
+	// so handlers can tell DI-provided services apart from request parameters.
+	providedTypes = make(map[reflect.Type]struct{})
+	providedLock  sync.RWMutex
+)
+
+var (
+	typeOfDigOut = reflect.TypeOf(dig.Out{})
+	typeOfError  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 func Container() *dig.Container {
 	return container
 }
@@ -23,5 +39,63 @@ func Provide(constructs ...any) {
 		if err := container.Provide(construct); err != nil {
 			panic(err)
 		}
+		recordProvidedTypes(reflect.TypeOf(construct))
+	}
+}
+
+// IsProvided reports whether a constructor registered by Provide produces the type.
+func IsProvided(typ reflect.Type) bool {
+	providedLock.RLock()
+	defer providedLock.RUnlock()
+	_, ok := providedTypes[typ]
+	return ok
+}
+
+// Resolve fetches a value of the given type from the container
+func Resolve(typ reflect.Type) (value reflect.Value, err error) {
+	fnType := reflect.FuncOf([]reflect.Type{typ}, nil, false)
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		value = args[0]
+		return nil
+	})
+	err = container.Invoke(fn.Interface())
+	return
+}
+
+func recordProvidedTypes(ctor reflect.Type) {
+	if ctor == nil || ctor.Kind() != reflect.Func {
+		return
+	}
+	providedLock.Lock()
+	defer providedLock.Unlock()
+	for i := 0; i < ctor.NumOut(); i++ {
+		out := ctor.Out(i)
+		if out == typeOfError {
+			continue
+		}
+		if isDigOut(out) {
+			// dig.Out struct, every exported field except group members is a result
+			for j := 0; j < out.NumField(); j++ {
+				sf := out.Field(j)
+				if sf.Anonymous || sf.PkgPath != "" || sf.Tag.Get("group") != "" {
+					continue
+				}
+				providedTypes[sf.Type] = struct{}{}
+			}
+			continue
+		}
+		providedTypes[out] = struct{}{}
+	}
+}
+
+func isDigOut(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.Anonymous && sf.Type == typeOfDigOut {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,124 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package param
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"reflect"
+	"sync"
+)
+
+// Source is where a typed parameter is read from
+type Source int
+
+const (
+	SourceQuery  Source = iota // query string, e.g. ?page=1
+	SourcePath                 // dynamic router args, see CmdArgs
+	SourceHeader               // request header
+	SourceBody                 // the whole request body
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceQuery:
+		return "query"
+	case SourcePath:
+		return "path"
+	case SourceHeader:
+		return "header"
+	case SourceBody:
+		return "body"
+	}
+	return "unknown"
+}
+
+// Typed is implemented by the pointer of every typed parameter wrapper,
+// Jet binds the value returned by ParamValue from the source returned by ParamSource.
+// The wrappers are transparent to json, they are encoded and decoded as the wrapped value.
+type Typed interface {
+	ParamSource() Source
+	ParamValue() reflect.Value
+}
+
+// Query is a scalar or slice read from the query string, like
+//
+//	func (c *Controller) GetV1UsageWeek(page param.Query[int], ids param.Query[[]int64]) error
+//
+// The name is taken from the struct tag `query` (or `form`) when used as a field,
+// or from the names registered by Names when used as a method parameter.
+type Query[T any] struct {
+	Value T
+}
+
+func (q Query[T]) Get() T                           { return q.Value }
+func (*Query[T]) ParamSource() Source               { return SourceQuery }
+func (q *Query[T]) ParamValue() reflect.Value       { return reflect.ValueOf(&q.Value).Elem() }
+func (q Query[T]) MarshalJSON() ([]byte, error)     { return utils.ObjToByte(q.Value) }
+func (q *Query[T]) UnmarshalJSON(data []byte) error { return utils.ByteToObj(data, &q.Value) }
+
+// Path is a dynamic router arg, like the `0` of GetV1Usage0Week.
+// Method parameters consume the args in order, fields use the index in the struct tag `path`.
+type Path[T any] struct {
+	Value T
+}
+
+func (p Path[T]) Get() T                           { return p.Value }
+func (*Path[T]) ParamSource() Source               { return SourcePath }
+func (p *Path[T]) ParamValue() reflect.Value       { return reflect.ValueOf(&p.Value).Elem() }
+func (p Path[T]) MarshalJSON() ([]byte, error)     { return utils.ObjToByte(p.Value) }
+func (p *Path[T]) UnmarshalJSON(data []byte) error { return utils.ByteToObj(data, &p.Value) }
+
+// Header is read from the request header named by the struct tag `header` or by Names
+type Header[T any] struct {
+	Value T
+}
+
+func (h Header[T]) Get() T                           { return h.Value }
+func (*Header[T]) ParamSource() Source               { return SourceHeader }
+func (h *Header[T]) ParamValue() reflect.Value       { return reflect.ValueOf(&h.Value).Elem() }
+func (h Header[T]) MarshalJSON() ([]byte, error)     { return utils.ObjToByte(h.Value) }
+func (h *Header[T]) UnmarshalJSON(data []byte) error { return utils.ByteToObj(data, &h.Value) }
+
+// Body is the whole request body, string and []byte get the raw body,
+// any other type is decoded like an ordinary request parameter.
+type Body[T any] struct {
+	Value T
+}
+
+func (b Body[T]) Get() T                           { return b.Value }
+func (*Body[T]) ParamSource() Source               { return SourceBody }
+func (b *Body[T]) ParamValue() reflect.Value       { return reflect.ValueOf(&b.Value).Elem() }
+func (b Body[T]) MarshalJSON() ([]byte, error)     { return utils.ObjToByte(b.Value) }
+func (b *Body[T]) UnmarshalJSON(data []byte) error { return utils.ByteToObj(data, &b.Value) }
+
+// ---------------------------------------------------------------------------
+
+var (
+	names     = make(map[uintptr][]string)
+	namesLock sync.RWMutex
+)
+
+// Names registers the names of the Query and Header parameters of a handler method,
+// the i-th name is used by the i-th Query or Header parameter, like
+//
+//	param.Names((*Controller).GetV1UsageWeek, "page", "ids")
+//
+// method must be a method expression of the pointer receiver.
+func Names(method any, paramNames ...string) {
+	v := reflect.ValueOf(method)
+	if v.Kind() != reflect.Func {
+		panic("param.Names: method is not a func")
+	}
+	namesLock.Lock()
+	defer namesLock.Unlock()
+	names[v.Pointer()] = paramNames
+}
+
+// NamesOf returns the names registered for the method func
+func NamesOf(method reflect.Value) []string {
+	namesLock.RLock()
+	defer namesLock.RUnlock()
+	return names[method.Pointer()]
+}
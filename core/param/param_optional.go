@@ -0,0 +1,102 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package param
+
+import (
+	"bytes"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"reflect"
+)
+
+var nullLiteral = []byte("null")
+
+// Optional tells apart a value absent from the request, an explicit null and a value, like
+//
+//	type PatchUserReq struct {
+//		Nickname param.Optional[string] `json:"nickname" form:"nickname"`
+//		Age      param.Optional[int]    `json:"age" form:"age" validate:"omitempty,min=1"`
+//	}
+//
+// For json, null is an explicit null. For the query string, form, header and path,
+// an empty value of a non-string type is an explicit null.
+// Validation by utils.Struct runs on the wrapped value, an absent or null Optional is empty,
+// wherever the Optional is, not only in a handler parameter, see utils.ValidationValuer.
+type Optional[T any] struct {
+	Value   T
+	Present bool // the request carries the field, null included
+	Null    bool // the field is an explicit null
+}
+
+// Some returns a present Optional of v
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// Null returns a present Optional of an explicit null
+func Null[T any]() Optional[T] {
+	return Optional[T]{Present: true, Null: true}
+}
+
+// Get returns the value and whether it is present and not null
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present && !o.Null
+}
+
+// OrElse returns the value if it is present and not null, otherwise def
+func (o Optional[T]) OrElse(def T) T {
+	if o.Present && !o.Null {
+		return o.Value
+	}
+	return def
+}
+
+func (o Optional[T]) IsPresent() bool { return o.Present }
+func (o Optional[T]) IsNull() bool    { return o.Null }
+
+// ValueInterface returns the value for validation, nil if it is absent or null
+func (o Optional[T]) ValueInterface() any {
+	if !o.Present || o.Null {
+		return nil
+	}
+	return o.Value
+}
+
+// OptionalTarget returns the addressable value to bind into
+func (o *Optional[T]) OptionalTarget() reflect.Value { return reflect.ValueOf(&o.Value).Elem() }
+
+// MarkPresent marks the Optional present, and an explicit null if null is true
+func (o *Optional[T]) MarkPresent(null bool) {
+	o.Present, o.Null = true, null
+	if null {
+		var zero T
+		o.Value = zero
+	}
+}
+
+// MarshalJSON encodes an absent or null Optional as null
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.Present || o.Null {
+		return nullLiteral, nil
+	}
+	return utils.ObjToByte(o.Value)
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), nullLiteral) {
+		o.MarkPresent(true)
+		return nil
+	}
+	o.MarkPresent(false)
+	return utils.ByteToObj(data, &o.Value)
+}
+
+// OptionalValue is implemented by Optional[T], see Optional
+type OptionalValue interface {
+	IsPresent() bool
+	IsNull() bool
+	ValueInterface() any
+	OptionalTarget() reflect.Value
+	MarkPresent(null bool)
+}
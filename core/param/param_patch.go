@@ -0,0 +1,436 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package param
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchKind is the media type of a Patch document
+type PatchKind int
+
+const (
+	MergePatch PatchKind = iota + 1 // application/merge-patch+json, RFC 7396
+	JSONPatch                       // application/json-patch+json, RFC 6902
+)
+
+func (k PatchKind) String() string {
+	switch k {
+	case MergePatch:
+		return constant.MIMEApplicationMergePatch
+	case JSONPatch:
+		return constant.MIMEApplicationJSONPatch
+	}
+	return "unknown"
+}
+
+// Patch is the body of a PATCH request, a JSON Merge Patch or a JSON Patch told by the Content-Type, like
+//
+//	func (c *Controller) PatchV1User0(id param.Path[int64], patch *param.Patch) (error, *User) {
+//		user := c.users.Get(id.Get())
+//		if err := patch.ApplyTo(user); err != nil {
+//			return err, nil
+//		}
+//		return c.users.Save(user), user
+//	}
+//
+// Any other Content-Type is rejected with 415.
+type Patch struct {
+	Kind     PatchKind
+	Document []byte
+}
+
+// ApplyTo applies the patch onto target, a pointer to the existing value, and validates the result by the validate tags.
+// The fields of a struct that are not encoded as json, like IDs tagged json:"-", keep their values.
+// The error is a 422 *constant.Error carrying the failing operation, target is left untouched in that case.
+func (p *Patch) ApplyTo(target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("patch: target must be a non-nil pointer, got %T", target)
+	}
+	doc, err := utils.ObjToByte(target)
+	if err != nil {
+		return err
+	}
+	if doc, err = p.Apply(doc); err != nil {
+		return err
+	}
+	patched := reflect.New(rv.Type().Elem())
+	if patched.Elem().Kind() == reflect.Struct {
+		// the fields json does not see, like json:"-" and unexported ones, are kept
+		patched.Elem().Set(rv.Elem())
+		clearJSONFields(patched.Elem())
+	}
+	if err = utils.ByteToObj(doc, patched.Interface()); err != nil {
+		return constant.NewError(constant.StatusUnprocessableEntity, "patch: "+err.Error())
+	}
+	if patched.Elem().Kind() == reflect.Struct {
+		if err = utils.Struct(patched.Interface()); err != nil {
+			return constant.NewError(constant.StatusUnprocessableEntity, "patch: "+err.Error())
+		}
+	}
+	rv.Elem().Set(patched.Elem())
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// clearJSONFields zeroes the fields of the struct that are decoded from json, so that the fields removed
+// by a patch are zero after decoding the patched document, and the others keep their values.
+// Nested structs are cleared field by field, but the ones decoding themselves like time.Time.
+func clearJSONFields(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Tag.Get("json") == "-" || !fv.CanSet() && !f.Anonymous {
+			continue
+		}
+		if fv.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(jsonUnmarshalerType) &&
+			!reflect.PtrTo(f.Type).Implements(textUnmarshalerType) {
+			clearJSONFields(fv)
+		} else if fv.CanSet() {
+			fv.Set(reflect.Zero(f.Type))
+		}
+	}
+}
+
+// Apply applies the patch onto the json document doc and returns the patched document
+func (p *Patch) Apply(doc []byte) ([]byte, error) {
+	var target any
+	if err := utils.ByteToObj(doc, &target); err != nil {
+		return nil, constant.NewError(constant.StatusUnprocessableEntity, "patch: invalid target document: "+err.Error())
+	}
+	switch p.Kind {
+	case MergePatch:
+		var patch any
+		if err := utils.ByteToObj(p.Document, &patch); err != nil {
+			return nil, constant.NewError(constant.StatusUnprocessableEntity, "merge patch: "+err.Error())
+		}
+		target = mergePatch(target, patch)
+	case JSONPatch:
+		var ops []patchOperation
+		if err := utils.ByteToObj(p.Document, &ops); err != nil {
+			return nil, constant.NewError(constant.StatusUnprocessableEntity, "json patch: "+err.Error())
+		}
+		for i, op := range ops {
+			var err error
+			if target, err = op.apply(target); err != nil {
+				return nil, constant.NewError(constant.StatusUnprocessableEntity,
+					fmt.Sprintf("json patch: operation %d (%s %s): %v", i, op.Op, op.Path, err))
+			}
+		}
+	default:
+		return nil, constant.NewError(constant.StatusUnsupportedMediaType, "patch: unknown patch kind")
+	}
+	return utils.ObjToByte(target)
+}
+
+// mergePatch implements the MergePatch function of RFC 7396, section 2
+func mergePatch(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = make(map[string]any, len(pm))
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = mergePatch(tm[k], v)
+		}
+	}
+	return tm
+}
+
+type patchOperation struct {
+	Op    string     `json:"op"`
+	Path  string     `json:"path"`
+	From  string     `json:"from"`
+	Value patchValue `json:"value"`
+}
+
+// patchValue keeps the raw value of an operation, telling a missing value from null
+type patchValue struct {
+	raw []byte
+}
+
+func (v *patchValue) UnmarshalJSON(data []byte) error {
+	v.raw = append([]byte(nil), data...)
+	return nil
+}
+
+var errPathNotFound = errors.New("path not found")
+
+func (op *patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value.raw == nil {
+			return nil, errors.New("missing value")
+		}
+		var value any
+		if err = utils.ByteToObj(op.Value.raw, &value); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return addValue(doc, path, value)
+		case "replace":
+			return replaceValue(doc, path, value)
+		}
+		var current any
+		if current, err = getValue(doc, path); err != nil {
+			return nil, err
+		}
+		if !jsonEqual(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	case "remove":
+		return removeValue(doc, path)
+	case "move", "copy":
+		var from []string
+		if from, err = parsePointer(op.From); err != nil {
+			return nil, err
+		}
+		var value any
+		if value, err = getValue(doc, from); err != nil {
+			return nil, fmt.Errorf("from %s: %w", op.From, err)
+		}
+		if op.Op == "copy" {
+			return addValue(doc, path, copyValue(value))
+		}
+		if op.Path == op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("can not move a value into one of its children")
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer parses a JSON Pointer of RFC 6901, "" is the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the token as an index of an array of n elements, n itself is accepted if appending is true
+func arrayIndex(token string, n int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (i == n && !appending) {
+		return 0, errPathNotFound
+	}
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			var ok bool
+			if doc, ok = node[token]; !ok {
+				return nil, errPathNotFound
+			}
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, errPathNotFound
+		}
+	}
+	return doc, nil
+}
+
+// updateParent walks to the parent of the last token of path and replaces it with the one returned by f,
+// arrays are replaced as a whole since they may grow or shrink.
+func updateParent(doc any, path []string, f func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+	child, err := getValue(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updateParent(child, path[1:], f); err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		node[path[0]] = child
+	case []any:
+		i, _ := strconv.Atoi(path[0])
+		node[i] = child
+	}
+	return doc, nil
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, errPathNotFound
+	})
+}
+
+func removeValue(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("can not remove the whole document")
+	}
+	return updateParent(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, errPathNotFound
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, errPathNotFound
+	})
+}
+
+func replaceValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, errPathNotFound
+			}
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+		return nil, errPathNotFound
+	})
+}
+
+func copyValue(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(node))
+		for k, e := range node {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(node))
+		for i, e := range node {
+			s[i] = copyValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual compares two decoded json values, numbers are equal if their values are equal, like 1 and 1.0
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, e := range x {
+			if f, ok := y[k]; !ok || !jsonEqual(e, f) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+	return a == b
+}
@@ -0,0 +1,106 @@
+package param
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7396, appendix A
+	for _, c := range []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		p := &Patch{Kind: MergePatch, Document: []byte(c.patch)}
+		doc, err := p.Apply([]byte(c.target))
+		assert.NoError(t, err)
+		assert.JSONEq(t, c.want, string(doc), c.patch)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// RFC 6902, appendix A
+	for _, c := range []struct{ target, patch, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"copy","from":"/~1","path":"/a"}]`, `{"/":9,"~1":10,"a":9}`},
+	} {
+		p := &Patch{Kind: JSONPatch, Document: []byte(c.patch)}
+		doc, err := p.Apply([]byte(c.target))
+		assert.NoError(t, err, c.patch)
+		assert.JSONEq(t, c.want, string(doc), c.patch)
+	}
+
+	for patch, msg := range map[string]string{
+		`[{"op":"add","path":"/baz/bat","value":"qux"}]`:                                   "operation 0 (add /baz/bat): path not found",
+		`[{"op":"replace","path":"/foo","value":1},{"op":"test","path":"/foo","value":2}]`: "operation 1 (test /foo): test failed",
+		`[{"op":"remove","path":"/foo/01"}]`:                                               "invalid array index",
+		`[{"op":"jump","path":"/foo"}]`:                                                    `unknown op "jump"`,
+		`[{"op":"add","path":"/foo"}]`:                                                     "missing value",
+	} {
+		p := &Patch{Kind: JSONPatch, Document: []byte(patch)}
+		_, err := p.Apply([]byte(`{"foo":["bar"]}`))
+		var e *constant.Error
+		if assert.ErrorAs(t, err, &e, patch) {
+			assert.Equal(t, constant.StatusUnprocessableEntity, e.Code)
+			assert.Contains(t, e.Message, msg)
+		}
+	}
+}
+
+type patchedAudit struct {
+	CreatedBy string `json:"created_by"`
+	version   int
+}
+
+type patchedUser struct {
+	patchedAudit
+	Id   int64    `json:"-"`
+	Name string   `json:"name" validate:"required"`
+	Age  int      `json:"age" validate:"min=1"`
+	Tags []string `json:"tags"`
+	etag string
+}
+
+func TestPatchApplyTo(t *testing.T) {
+	user := &patchedUser{Name: "jet", Age: 3, Tags: []string{"a"}}
+	p := &Patch{Kind: JSONPatch, Document: []byte(`[{"op":"add","path":"/tags/-","value":"b"},{"op":"replace","path":"/age","value":4}]`)}
+	assert.NoError(t, p.ApplyTo(user))
+	assert.Equal(t, &patchedUser{Name: "jet", Age: 4, Tags: []string{"a", "b"}}, user)
+
+	p = &Patch{Kind: MergePatch, Document: []byte(`{"age":0}`)}
+	var e *constant.Error
+	assert.ErrorAs(t, p.ApplyTo(user), &e)
+	assert.Equal(t, constant.StatusUnprocessableEntity, e.Code)
+	assert.Equal(t, 4, user.Age, "target is untouched when the result is invalid")
+
+	p = &Patch{Kind: MergePatch, Document: []byte(`{"name":"web","tags":null}`)}
+	assert.NoError(t, p.ApplyTo(user))
+	assert.Equal(t, &patchedUser{Name: "web", Age: 4}, user)
+
+	// the fields that are not encoded as json survive, the removed ones are zeroed
+	user = &patchedUser{patchedAudit: patchedAudit{CreatedBy: "root", version: 2}, Id: 7, Name: "jet", Age: 3, etag: "v2"}
+	p = &Patch{Kind: MergePatch, Document: []byte(`{"created_by":null,"age":5}`)}
+	assert.NoError(t, p.ApplyTo(user))
+	assert.Equal(t, &patchedUser{patchedAudit: patchedAudit{version: 2}, Id: 7, Name: "jet", Age: 5, etag: "v2"}, user)
+}
@@ -28,7 +28,12 @@ func (r *JetRouter) RegisterRouter(path string, handler handler.IHandler) {
 
 func (r *JetRouter) ServeHTTP(ctx *fasthttp.RequestCtx) {
 	requestURI := convertToFirstLetterUpper(ctx.Method()) + string(ctx.URI().PathOriginal())
-	if h, queryPathArgs := r.trie.GetAndArgs(requestURI); h != nil {
+	h, queryPathArgs := r.trie.GetAndArgs(requestURI)
+	if h == nil && ctx.IsHead() {
+		// HEAD is answered by the GET route without the body
+		h, queryPathArgs = r.trie.GetAndArgs(convertToFirstLetterUpper([]byte(fasthttp.MethodGet)) + string(ctx.URI().PathOriginal()))
+	}
+	if h != nil {
 		h.ServeHTTP(ctx, queryPathArgs)
 	} else {
 		handler.NotFoundHandler(ctx)
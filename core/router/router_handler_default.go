@@ -14,7 +14,10 @@ import (
 	"strings"
 )
 
-var DefaultJetRouter = NewJetRouter("0")
+// defaultSeparator marks the dynamic parts of the controller methods of DefaultJetRouter, like GetV1User0
+const defaultSeparator = "0"
+
+var DefaultJetRouter = NewJetRouter(defaultSeparator)
 
 func ServeHTTP(ctx *fasthttp.RequestCtx) {
 	DefaultJetRouter.ServeHTTP(ctx)
@@ -61,6 +64,7 @@ func register(rcvr interface{}) {
 		}
 		h.AddHook(hooks)
 		DefaultJetRouter.RegisterRouter(method.Name, h)
+		routeNames.Store(method.Name, struct{}{})
 		xlog.Debug("Install", "=>", method.Name)
 	}
 }
@@ -5,7 +5,10 @@
 package router
 
 import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/hook"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 	"testing"
 )
 
@@ -27,3 +30,25 @@ func TestJetRouter_RegisterRouter(t *testing.T) {
 	xlog.SetOutputLevel(xlog.Ldebug)
 	Register(Controller{}, &Controller{})
 }
+
+type methodHandler string
+
+func (h methodHandler) ServeHTTP(ctx *fasthttp.RequestCtx, args []string) {
+	ctx.SetBodyString(string(h))
+}
+
+func (h methodHandler) AddHook(hooks *hook.Hook) {}
+
+func TestJetRouter_Head(t *testing.T) {
+	r := NewJetRouter("0")
+	r.RegisterRouter("GetV1User", methodHandler("get"))
+	r.RegisterRouter("GetV1Report", methodHandler("get"))
+	r.RegisterRouter("HeadV1Report", methodHandler("head"))
+	for path, want := range map[string]string{"/v1/user": "get", "/v1/report": "head"} {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodHead)
+		ctx.Request.SetRequestURI(path)
+		r.ServeHTTP(ctx)
+		assert.Equal(t, want, string(ctx.Response.Body()), path)
+	}
+}
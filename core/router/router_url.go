@@ -0,0 +1,47 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// routeNames records the controller methods registered to DefaultJetRouter for URLFor
+var routeNames sync.Map // map[string]struct{}
+
+// URLFor returns the path of a registered controller method, the args fill its dynamic parts in order, like
+//
+//	router.URLFor("GetV1User0Posts", 42) // /v1/user/42/posts
+func URLFor(method string, args ...any) (string, error) {
+	if _, ok := routeNames.Load(method); !ok {
+		return "", fmt.Errorf("router: no route of the method %s", method)
+	}
+	pattern := splitCamelCaseFunc(method, defaultSeparator)
+	if len(pattern) < 2 {
+		return "", fmt.Errorf("router: invalid route method %s", method)
+	}
+	var sb strings.Builder
+	n := 0
+	// the first part is the http method
+	for _, part := range pattern[1:] {
+		sb.WriteByte('/')
+		if part != defaultSeparator {
+			sb.WriteString(part)
+			continue
+		}
+		if n == len(args) {
+			return "", fmt.Errorf("router: %s needs more than %d args", method, len(args))
+		}
+		sb.WriteString(url.PathEscape(fmt.Sprint(args[n])))
+		n++
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("router: %s needs %d args, got %d", method, n, len(args))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,38 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type urlController struct{}
+
+func (c *urlController) GetV1Report() error {
+	return nil
+}
+
+func (c *urlController) GetV1User0Posts0() error {
+	return nil
+}
+
+func TestURLFor(t *testing.T) {
+	Register(&urlController{})
+	u, err := URLFor("GetV1Report")
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1/report", u)
+
+	u, err = URLFor("GetV1User0Posts0", 42, "a b")
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1/user/42/posts/a%20b", u)
+
+	_, err = URLFor("GetV1User0Posts0", 42)
+	assert.Error(t, err)
+	_, err = URLFor("GetV1Report", 1)
+	assert.Error(t, err)
+	_, err = URLFor("GetV1Missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package view
+
+import (
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/router"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// PartialsDir is the directory of the partials, every template under it can be included by any view, like
+//
+//	{{ template "partials/nav" . }}
+const PartialsDir = "partials"
+
+// Config configures an Engine
+type Config struct {
+	Dir    string // the directory of the templates, used if FS is nil
+	FS     fs.FS  // the templates, like an embed.FS
+	Ext    string // the extension of the templates, .html by default
+	Layout string // the layout of the views by default, like "layouts/main", empty for none
+	Reload bool   // re-read the templates on every render, for development
+	Funcs  template.FuncMap
+}
+
+// Engine renders the views of a directory of html/template files.
+// A template is named by its path without the extension, like "users/list".
+//
+// A layout renders the view it wraps by {{ template "content" . }}, and the view can override
+// the blocks of the layout, like {{ define "title" }}Users{{ end }}.
+// Besides the functions of Config, the templates can call
+//
+//	{{ url "GetV1User0" .Id }}  the path of a controller method, see router.URLFor
+//	{{ csrfToken }}             the csrf token of the request, see CSRFToken
+//	{{ csrfField }}             a hidden form input of the csrf token
+type Engine struct {
+	config Config
+	fsys   fs.FS
+	lock   sync.RWMutex
+	files  map[string]string             // the sources by name
+	sets   map[string]*template.Template // the parsed layout and view pairs
+}
+
+// New returns an Engine of the config, the templates are read and checked for syntax errors at once
+func New(config Config) (*Engine, error) {
+	if config.Ext == "" {
+		config.Ext = ".html"
+	}
+	e := &Engine{config: config, fsys: config.FS}
+	if e.fsys == nil {
+		if config.Dir == "" {
+			return nil, fmt.Errorf("view: neither Dir nor FS is configured")
+		}
+		e.fsys = os.DirFS(config.Dir)
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// load reads every template and checks its syntax
+func (e *Engine) load() error {
+	files := make(map[string]string)
+	err := fs.WalkDir(e.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != e.config.Ext {
+			return err
+		}
+		b, err := fs.ReadFile(e.fsys, name)
+		if err != nil {
+			return err
+		}
+		name = strings.TrimSuffix(name, e.config.Ext)
+		if _, err = template.New(name).Funcs(e.funcs()).Parse(string(b)); err != nil {
+			return fmt.Errorf("view: %w", err)
+		}
+		files[name] = string(b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.files, e.sets = files, make(map[string]*template.Template)
+	return nil
+}
+
+// Render renders the view wrapped by the layout, the one of Config if layout is empty, "-" for none.
+// funcs replaces the functions of the templates for this render, like the request bound ones of RequestFuncs.
+func (e *Engine) Render(w io.Writer, name, layout string, model any, funcs template.FuncMap) error {
+	if e.config.Reload {
+		if err := e.load(); err != nil {
+			return err
+		}
+	}
+	switch layout {
+	case "":
+		layout = e.config.Layout
+	case "-":
+		layout = ""
+	}
+	set, err := e.set(name, layout)
+	if err != nil {
+		return err
+	}
+	// a clone is never executed, so the funcs can be replaced
+	t, err := set.Clone()
+	if err != nil {
+		return err
+	}
+	if funcs != nil {
+		t.Funcs(funcs)
+	}
+	return t.Execute(w, model)
+}
+
+// set returns the parsed templates of the view and layout along with the partials
+func (e *Engine) set(name, layout string) (*template.Template, error) {
+	key := layout + "\x00" + name
+	e.lock.RLock()
+	set, ok := e.sets[key]
+	files := e.files
+	e.lock.RUnlock()
+	if ok {
+		return set, nil
+	}
+	view, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("view: no template %s", name)
+	}
+	root := name
+	if layout != "" {
+		root = layout
+	}
+	set = template.New(root).Funcs(e.funcs())
+	for partial, src := range files {
+		if strings.HasPrefix(partial, PartialsDir+"/") && partial != root {
+			if _, err := set.New(partial).Parse(src); err != nil {
+				return nil, fmt.Errorf("view: %w", err)
+			}
+		}
+	}
+	if layout != "" {
+		src, ok := files[layout]
+		if !ok {
+			return nil, fmt.Errorf("view: no layout %s", layout)
+		}
+		if _, err := set.Parse(src); err != nil {
+			return nil, fmt.Errorf("view: %w", err)
+		}
+		if _, err := set.New("content").Parse(view); err != nil {
+			return nil, fmt.Errorf("view: %w", err)
+		}
+	} else if _, err := set.Parse(view); err != nil {
+		return nil, fmt.Errorf("view: %w", err)
+	}
+	if !e.config.Reload {
+		e.lock.Lock()
+		e.sets[key] = set
+		e.lock.Unlock()
+	}
+	return set, nil
+}
+
+// funcs returns the functions of the templates, the request bound ones return nothing without a request
+func (e *Engine) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"url":       router.URLFor,
+		"csrfToken": func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
+	}
+	for k, f := range e.config.Funcs {
+		funcs[k] = f
+	}
+	return funcs
+}
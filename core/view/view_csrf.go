@@ -0,0 +1,84 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package view
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"html"
+	"html/template"
+)
+
+const (
+	// CSRFCookie is the cookie keeping the csrf token of the client
+	CSRFCookie = "_csrf"
+	// CSRFField is the form field carrying the csrf token, as rendered by {{ csrfField }}
+	CSRFField = "_csrf"
+	// CSRFHeader is the header carrying the csrf token, for requests made by scripts
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfKey       = "jet_csrf_token"
+	csrfTokenSize = 32
+)
+
+// CSRFToken returns the csrf token of the client, a new one is set as the CSRFCookie.
+// The unsafe requests are checked against it by VerifyCSRF, which is the double submit cookie pattern.
+func CSRFToken(ctx *fasthttp.RequestCtx) string {
+	if token, ok := ctx.UserValue(csrfKey).(string); ok {
+		return token
+	}
+	token := string(ctx.Request.Header.Cookie(CSRFCookie))
+	if b, err := base64.RawURLEncoding.DecodeString(token); err != nil || len(b) != csrfTokenSize {
+		b = make([]byte, csrfTokenSize)
+		if _, err = rand.Read(b); err != nil {
+			panic(err)
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		cookie.SetKey(CSRFCookie)
+		cookie.SetValue(token)
+		cookie.SetPath("/")
+		cookie.SetHTTPOnly(true)
+		cookie.SetSecure(ctx.IsTLS())
+		cookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
+		ctx.Response.Header.SetCookie(cookie)
+	}
+	ctx.SetUserValue(csrfKey, token)
+	return token
+}
+
+// VerifyCSRF returns 403 for an unsafe request whose CSRFHeader or CSRFField does not match the CSRFCookie
+func VerifyCSRF(ctx *fasthttp.RequestCtx) error {
+	switch string(ctx.Method()) {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return nil
+	}
+	cookie := ctx.Request.Header.Cookie(CSRFCookie)
+	token := ctx.Request.Header.Peek(CSRFHeader)
+	if len(token) == 0 {
+		token = ctx.FormValue(CSRFField)
+	}
+	if len(cookie) == 0 || subtle.ConstantTimeCompare(cookie, token) != 1 {
+		return constant.NewError(constant.StatusForbidden, "invalid csrf token")
+	}
+	return nil
+}
+
+// RequestFuncs returns the template functions bound to the request, to be passed to Engine.Render
+func RequestFuncs(ctx *fasthttp.RequestCtx) template.FuncMap {
+	return template.FuncMap{
+		"csrfToken": func() string {
+			return CSRFToken(ctx)
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + CSRFField + `" value="` +
+				html.EscapeString(CSRFToken(ctx)) + `">`)
+		},
+	}
+}
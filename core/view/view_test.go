@@ -0,0 +1,84 @@
+package view
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var views = fstest.MapFS{
+	"layouts/main.html": {Data: []byte(`<title>{{ block "title" . }}Jet{{ end }}</title>{{ template "partials/nav" . }}<main>{{ template "content" . }}</main>`)},
+	"partials/nav.html": {Data: []byte(`<nav>{{ upper .User }}</nav>`)},
+	"users/list.html":   {Data: []byte(`{{ define "title" }}Users{{ end }}{{ range .Names }}<li>{{ . }}</li>{{ end }}`)},
+	"users/form.html":   {Data: []byte(`<form>{{ csrfField }}</form>`)},
+	"notes.txt":         {Data: []byte(`{{ not a template`)},
+}
+
+func TestEngine(t *testing.T) {
+	e, err := New(Config{FS: views, Layout: "layouts/main", Funcs: template.FuncMap{"upper": strings.ToUpper}})
+	assert.NoError(t, err)
+	model := map[string]any{"User": "jet", "Names": []string{"a", "<b>"}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, e.Render(&buf, "users/list", "", model, nil))
+	assert.Equal(t, `<title>Users</title><nav>JET</nav><main><li>a</li><li>&lt;b&gt;</li></main>`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, e.Render(&buf, "users/list", "-", model, nil))
+	assert.Equal(t, `<li>a</li><li>&lt;b&gt;</li>`, buf.String())
+
+	assert.Error(t, e.Render(&buf, "users/missing", "", model, nil))
+	assert.Error(t, e.Render(&buf, "users/list", "layouts/missing", model, nil))
+
+	_, err = New(Config{FS: fstest.MapFS{"broken.html": {Data: []byte(`{{ if }}`)}}})
+	assert.Error(t, err)
+}
+
+func TestEngineReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	assert.NoError(t, os.WriteFile(file, []byte(`v1`), 0o644))
+	e, err := New(Config{Dir: dir, Reload: true})
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, e.Render(&buf, "index", "", nil, nil))
+	assert.NoError(t, os.WriteFile(file, []byte(`v2`), 0o644))
+	assert.NoError(t, e.Render(&buf, "index", "", nil, nil))
+	assert.Equal(t, "v1v2", buf.String())
+}
+
+func TestCSRF(t *testing.T) {
+	e, err := New(Config{FS: fstest.MapFS{"form.html": views["users/form.html"]}})
+	assert.NoError(t, err)
+	ctx := new(fasthttp.RequestCtx)
+	var buf bytes.Buffer
+	assert.NoError(t, e.Render(&buf, "form", "", nil, RequestFuncs(ctx)))
+	token := CSRFToken(ctx)
+	assert.Len(t, token, 43)
+	assert.Equal(t, `<form><input type="hidden" name="_csrf" value="`+token+`"></form>`, buf.String())
+	cookie := fasthttp.AcquireCookie()
+	cookie.SetKey(CSRFCookie)
+	assert.True(t, ctx.Response.Header.Cookie(cookie))
+	assert.Equal(t, token, string(cookie.Value()))
+
+	post := func(cookie, form, header string) error {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+		ctx.Request.Header.SetCookie(CSRFCookie, cookie)
+		ctx.Request.Header.Set(CSRFHeader, header)
+		ctx.Request.SetBodyString(CSRFField + "=" + form)
+		return VerifyCSRF(ctx)
+	}
+	assert.NoError(t, post(token, token, ""))
+	assert.NoError(t, post(token, "", token))
+	assert.Error(t, post(token, "forged", ""))
+	assert.Error(t, post("", "", ""))
+	assert.NoError(t, VerifyCSRF(new(fasthttp.RequestCtx)))
+}
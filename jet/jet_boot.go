@@ -9,6 +9,7 @@ import (
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/inject"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/router"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/commands"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/xlog"
 	"github.com/valyala/fasthttp"
@@ -127,13 +128,27 @@ type BaseJetController struct {
 
 func (BaseJetController) PostParamsParseHook(param any) (err error) {
 	if err = utils.Struct(param); err != nil {
-		err = errors.New(utils.ObjToJsonStr(map[string]any{"code": 400, "message": "bad request", "data": utils.ProcessErr(param, err)}))
+		err = &ValidationError{Code: constant.StatusBadRequest, Message: "bad request", Data: utils.ProcessErr(param, err), err: err}
 	}
 	return
 }
 
-// PostMethodExecuteHook restful
+// ValidationError is the 400 of a parameter failing validation in BaseJetController.PostParamsParseHook,
+// its body keeps the shape Jet always wrote, like {"code":400,"message":"bad request","data":"..."}.
+// The fields failing validation are found by utils.FieldErrors, like by the envelope and the problem details.
+type ValidationError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	err     error
+}
+
+func (e *ValidationError) Error() string   { return e.Message }
+func (e *ValidationError) StatusCode() int { return e.Code }
+func (e *ValidationError) Unwrap() error   { return e.err }
+func (e *ValidationError) ErrorBody() any  { return e }
+
+// PostMethodExecuteHook restful, the result is encoded by the negotiated encoder and wrapped by the envelope, see UseEnvelope
 func (BaseJetController) PostMethodExecuteHook(param any) (data any, err error) {
-	// restful
-	return utils.ObjToJsonStr(param), nil
+	return param, nil
 }
@@ -73,18 +73,18 @@ func (j *jetController) PostV1UsageContext(ctx Ctx, req *req) (maps.IMap[string,
 	return ctx.Keys(), nil
 }
 
-type Response struct {
+type usageResponse struct {
 	RequestId string `json:"request_id,omitempty"` //请求ID
 	Code      int    `json:"code"`                 //错误码，200 成功，其他失败
 	Message   string `json:"message,omitempty"`    //错误信息
 	Data      any    `json:"data,omitempty"`
 }
 
-func (j *jetController) GetV1UsageContext(ctx Ctx, req *req) (*Response, error) {
+func (j *jetController) GetV1UsageContext(ctx Ctx, req *req) (*usageResponse, error) {
 	ctx.Logger().Info("GetV1UsageContext")
 	ctx.Logger().Infof("req:%v", req)
 	ctx.Put("traceId", ctx.Logger().ReqId)
-	return &Response{Data: ctx.Keys()}, nil
+	return &usageResponse{Data: ctx.Keys()}, nil
 }
 
 func (j *jetController) GetV1UsageContext0(ctx Ctx, args *context.Args) (map[string]any, error) {
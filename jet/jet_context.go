@@ -4,7 +4,11 @@
 
 package jet
 
-import "github.com/fengyuan-liang/jet-web-fasthttp/core/context"
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/context"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/param"
+)
 
 // Ctx is the most important part of Jet. It allows us to pass variables between middleware,
 // manage the flow, validate the JSON of a request and render a JSON response for example.
@@ -17,3 +21,59 @@ type Args struct {
 	FormParam1 string `json:"form_param1" form:"form_param1"`
 	FormParam2 string `json:"form_param2" form:"form_param1"`
 }
+
+// ParamNames registers the names of the param.Query and param.Header parameters of a controller method,
+// see param.Names
+func ParamNames(method any, names ...string) {
+	param.Names(method, names...)
+}
+
+// RegisterConverter registers how to convert a request string into T, see handler.RegisterConverter
+func RegisterConverter[T any](f func(str string) (T, error)) {
+	handler.RegisterConverter(f)
+}
+
+// SetBindConfig sets the array format and the limits of nested query and form binding
+func SetBindConfig(config handler.BindConfig) {
+	handler.SetBindConfig(config)
+}
+
+// ConfigureRoute sets the per-route configuration of a controller method, see handler.ConfigureRoute
+func ConfigureRoute(method any, config handler.RouteConfig) {
+	handler.ConfigureRoute(method, config)
+}
+
+// RegisterDecoder registers how to bind a request body of the media type, see handler.RegisterDecoder
+func RegisterDecoder(mediaType string, f handler.DecoderFunc) {
+	handler.RegisterDecoder(mediaType, f)
+}
+
+// RegisterEncoder registers how to encode a response of the media type, see handler.RegisterEncoder
+func RegisterEncoder(mediaType string, f handler.EncoderFunc) {
+	handler.RegisterEncoder(mediaType, f)
+}
+
+// SetDefaultMediaType sets the media type of a response without an Accept header, see handler.SetDefaultMediaType
+func SetDefaultMediaType(mediaType string) {
+	handler.SetDefaultMediaType(mediaType)
+}
+
+// UseProblemDetails writes errors as RFC 7807 application/problem+json, see handler.RenderProblemDetails
+func UseProblemDetails() {
+	handler.SetErrorRenderer(handler.RenderProblemDetails)
+}
+
+// UseEnvelope wraps every result and error into the body returned by f, handler.DefaultEnvelope if f is nil,
+// see handler.SetEnvelope and handler.RouteConfig.RawResponse
+func UseEnvelope(f handler.EnvelopeFunc) {
+	if f == nil {
+		f = handler.DefaultEnvelope
+	}
+	handler.SetEnvelope(f)
+}
+
+// CheckPrecondition returns 412 if the If-Match or If-None-Match of an unsafe request do not hold for the current
+// etag of the resource, see handler.CheckPrecondition
+func CheckPrecondition(ctx Ctx, etag string) error {
+	return handler.CheckPrecondition(ctx.FastHttpCtx(), etag)
+}
@@ -0,0 +1,411 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRanges is the most ranges of a Range header answered with a multipart response, the whole file is sent beyond
+const maxRanges = 64
+
+// File is a return value streaming a file as the response body, like
+//
+//	func (c *ReportController) GetV1Report(id param.Path[string]) (jet.File, error) {
+//		return jet.FileOf(filepath.Join("reports", id.Get()+".pdf")).AsAttachment(), nil
+//	}
+//
+// It sets the Content-Type by the extension of the name or by sniffing the content, the Content-Disposition,
+// the Last-Modified and a strong ETag of the modification time and size, answers the If-None-Match and
+// If-Modified-Since headers with 304, and the Range and If-Range headers with partial responses.
+// The content is streamed and never loaded into memory as a whole, and closed after the response is written.
+type File struct {
+	name        string
+	contentType string
+	attachment  bool
+	open        func() (io.ReadSeeker, time.Time, error)
+}
+
+// FileOf returns a File of the path on disk, 404 if it does not exist or is a directory
+func FileOf(name string) File {
+	return File{name: filepath.Base(name), open: func() (io.ReadSeeker, time.Time, error) {
+		return openFile(os.Open(name))
+	}}
+}
+
+// FileFS returns a File of the name in fsys, like an embed.FS, whose files must implement io.Seeker
+func FileFS(fsys fs.FS, name string) File {
+	return File{name: path.Base(name), open: func() (io.ReadSeeker, time.Time, error) {
+		return openFile(fsys.Open(name))
+	}}
+}
+
+// FileContent returns a File of the content, name is the file name sent to the client and may be empty,
+// a zero modTime sends no Last-Modified. The content is closed after the response if it is an io.Closer.
+func FileContent(name string, modTime time.Time, content io.ReadSeeker) File {
+	return File{name: name, open: func() (io.ReadSeeker, time.Time, error) {
+		return content, modTime, nil
+	}}
+}
+
+func openFile(f fs.File, err error) (io.ReadSeeker, time.Time, error) {
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, time.Time{}, constant.ErrNotFound.Wrap(err)
+		}
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, time.Time{}, err
+	}
+	if info.IsDir() {
+		_ = f.Close()
+		return nil, time.Time{}, constant.ErrNotFound
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		_ = f.Close()
+		return nil, time.Time{}, fmt.Errorf("file %s does not implement io.Seeker", info.Name())
+	}
+	return rs, info.ModTime(), nil
+}
+
+// WithName returns a copy of the File sent to the client as name, which also decides the Content-Type
+func (f File) WithName(name string) File {
+	f.name = name
+	return f
+}
+
+// WithContentType returns a copy of the File of the Content-Type
+func (f File) WithContentType(contentType string) File {
+	f.contentType = contentType
+	return f
+}
+
+// AsAttachment returns a copy of the File downloaded by browsers instead of being displayed inline
+func (f File) AsAttachment() File {
+	f.attachment = true
+	return f
+}
+
+func (f File) WriteResponse(ctx *fasthttp.RequestCtx) (err error) {
+	if f.open == nil {
+		return constant.ErrNotFound
+	}
+	content, modTime, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			closeContent(content)
+		}
+	}()
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	contentType := f.contentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(f.name))
+	}
+	if contentType == "" {
+		// sniff the first bytes like http.ServeContent
+		var buf [512]byte
+		n, _ := io.ReadFull(content, buf[:])
+		contentType = http.DetectContentType(buf[:n])
+		if _, err = content.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	header := &ctx.Response.Header
+	header.SetServer("JetServer")
+	header.Set(constant.HeaderAcceptRanges, "bytes")
+	disposition := "inline"
+	if f.attachment {
+		disposition = "attachment"
+	}
+	header.Set(constant.HeaderContentDisposition, contentDisposition(disposition, f.name))
+	if !modTime.IsZero() && !modTime.Equal(time.Unix(0, 0)) {
+		header.Set(constant.HeaderLastModified, modTime.UTC().Format(http.TimeFormat))
+		if len(header.Peek(constant.HeaderETag)) == 0 {
+			header.Set(constant.HeaderETag, fileETag(modTime, size))
+		}
+	}
+	if handler.NotModified(ctx) {
+		closeContent(content)
+		ctx.SetStatusCode(constant.StatusNotModified)
+		return nil
+	}
+
+	var ranges []httpRange
+	if rangeHeader := string(ctx.Request.Header.Peek(constant.HeaderRange)); rangeHeader != "" && ctx.IsGet() &&
+		ifRangeMatches(ctx, modTime) {
+		if ranges, err = parseRange(rangeHeader, size); err != nil {
+			header.Set(constant.HeaderContentRange, "bytes */"+strconv.FormatInt(size, 10))
+			return constant.ErrRequestedRangeNotSatisfiable.Wrap(err)
+		}
+		if len(ranges) > maxRanges || sumRanges(ranges) > size {
+			// the client is asking for more than the file, send it as a whole
+			ranges = nil
+		}
+	}
+	switch len(ranges) {
+	case 0:
+		ctx.SetStatusCode(constant.StatusOK)
+		ctx.SetContentType(contentType)
+		ctx.SetBodyStream(&fileBody{Reader: content, content: content}, int(size))
+	case 1:
+		r := ranges[0]
+		ctx.SetStatusCode(constant.StatusPartialContent)
+		ctx.SetContentType(contentType)
+		header.Set(constant.HeaderContentRange, r.contentRange(size))
+		ctx.SetBodyStream(&fileBody{Reader: r.reader(content), content: content}, int(r.length))
+	default:
+		body, length := multipartRanges(ranges, content, contentType, size)
+		ctx.SetStatusCode(constant.StatusPartialContent)
+		ctx.SetContentType("multipart/byteranges; boundary=" + body.boundary)
+		ctx.SetBodyStream(body, int(length))
+	}
+	return nil
+}
+
+// fileETag returns the strong ETag of a file by its modification time and size, like nginx
+func fileETag(modTime time.Time, size int64) string {
+	return `"` + strconv.FormatInt(modTime.Unix(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// contentDisposition returns the Content-Disposition of the file name,
+// a non-ASCII name is sent as the filename* parameter of RFC 5987 with an ASCII fallback for old clients.
+func contentDisposition(disposition, name string) string {
+	if name == "" {
+		return disposition
+	}
+	fallback := []byte(name)
+	ascii := true
+	for i := 0; i < len(fallback); i++ {
+		if c := fallback[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			fallback[i], ascii = '_', false
+		}
+	}
+	disposition += `; filename="` + string(fallback) + `"`
+	if !ascii {
+		disposition += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return disposition
+}
+
+// encodeExtValue percent-encodes the UTF-8 bytes of s but the attr-char of RFC 5987
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0xf])
+		}
+	}
+	return sb.String()
+}
+
+// ifRangeMatches reports whether the Range header is applied, the If-Range is either
+// the Last-Modified of the file or a strong ETag matching the ETag of the response
+func ifRangeMatches(ctx *fasthttp.RequestCtx, modTime time.Time) bool {
+	ifRange := strings.TrimSpace(string(ctx.Request.Header.Peek(constant.HeaderIfRange)))
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		etag := string(ctx.Response.Header.Peek(constant.HeaderETag))
+		return etag != "" && etag == ifRange
+	}
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
+
+// httpRange is a range of a Range header resolved against the size of the file
+type httpRange struct {
+	start, length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+func (r httpRange) reader(content io.ReadSeeker) io.Reader {
+	return &sectionReader{content: content, offset: r.start, remaining: r.length}
+}
+
+var errNoOverlap = errors.New("no range overlaps the file")
+
+// parseRange parses a Range header of RFC 9110, section 14.2, like bytes=0-499, bytes=500- or bytes=-500
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errors.New("invalid range unit")
+	}
+	var ranges []httpRange
+	noOverlap := false
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		var r httpRange
+		if first == "" {
+			// a suffix range, the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if n == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = httpRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, fmt.Errorf("invalid range %q", spec)
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			r = httpRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errNoOverlap
+		}
+		return nil, errors.New("empty range")
+	}
+	return ranges, nil
+}
+
+func sumRanges(ranges []httpRange) (sum int64) {
+	for _, r := range ranges {
+		sum += r.length
+	}
+	return
+}
+
+// multipartRanges returns the multipart/byteranges body of the ranges and its length,
+// the part headers are rendered beforehand so that the length is known without reading the file.
+func multipartRanges(ranges []httpRange, content io.ReadSeeker, contentType string, size int64) (*fileBody, int64) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	readers := make([]io.Reader, 0, 2*len(ranges)+1)
+	var length int64
+	for _, r := range ranges {
+		_, _ = mw.CreatePart(textproto.MIMEHeader{
+			constant.HeaderContentType:  {contentType},
+			constant.HeaderContentRange: {r.contentRange(size)},
+		})
+		readers = append(readers, bytes.NewReader(append([]byte(nil), buf.Bytes()...)), r.reader(content))
+		length += int64(buf.Len()) + r.length
+		buf.Reset()
+	}
+	_ = mw.Close()
+	readers = append(readers, bytes.NewReader(buf.Bytes()))
+	length += int64(buf.Len())
+	return &fileBody{Reader: io.MultiReader(readers...), content: content, boundary: mw.Boundary()}, length
+}
+
+// sectionReader reads remaining bytes from offset, seeking on the first read
+// so that the sections of a multipart body share the content
+type sectionReader struct {
+	content   io.ReadSeeker
+	offset    int64
+	remaining int64
+	seeked    bool
+}
+
+func (r *sectionReader) Read(p []byte) (n int, err error) {
+	if !r.seeked {
+		if _, err = r.content.Seek(r.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		r.seeked = true
+	}
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err = r.content.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// fileBody is the body stream of a File, fasthttp closes it after the response is written
+type fileBody struct {
+	io.Reader
+	content  io.ReadSeeker
+	boundary string
+}
+
+func (b *fileBody) Close() error {
+	closeContent(b.content)
+	return nil
+}
+
+func closeContent(content io.ReadSeeker) {
+	if c, ok := content.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
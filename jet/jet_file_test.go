@@ -0,0 +1,146 @@
+package jet
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var fileModTime = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+type fileController struct {
+	dir string
+}
+
+func (c *fileController) GetV1Report() (File, error) {
+	return FileOf(filepath.Join(c.dir, "report.txt")).AsAttachment(), nil
+}
+
+func (c *fileController) GetV1Missing() (File, error) {
+	return FileOf(filepath.Join(c.dir, "missing.txt")), nil
+}
+
+func (c *fileController) GetV1Embedded() File {
+	fsys := fstest.MapFS{"static/报告.json": {Data: []byte(`{"ok":true}`), ModTime: fileModTime}}
+	return FileFS(fsys, "static/报告.json")
+}
+
+func (c *fileController) GetV1Content() File {
+	return FileContent("", time.Time{}, strings.NewReader("<html><body>jet</body></html>"))
+}
+
+func serveFile(t *testing.T, name string, header map[string]string) *fasthttp.RequestCtx {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "report.txt"), []byte("0123456789abcdefghij"), 0o644))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "report.txt"), fileModTime, fileModTime))
+	rcvr := reflect.ValueOf(&fileController{dir: dir})
+	m, _ := rcvr.Type().MethodByName(name)
+	h, err := handler.HandlerCreator{}.New(&rcvr, &m)
+	assert.NoError(t, err)
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/")
+	for k, v := range header {
+		ctx.Request.Header.Set(k, v)
+	}
+	h.ServeHTTP(ctx, nil)
+	return ctx
+}
+
+func TestFile(t *testing.T) {
+	ctx := serveFile(t, "GetV1Report", nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "0123456789abcdefghij", string(ctx.Response.Body()))
+	assert.Equal(t, "text/plain; charset=utf-8", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `attachment; filename="report.txt"`, string(ctx.Response.Header.Peek(constant.HeaderContentDisposition)))
+	assert.Equal(t, fileModTime.Format(http.TimeFormat), string(ctx.Response.Header.Peek(constant.HeaderLastModified)))
+	assert.Equal(t, "bytes", string(ctx.Response.Header.Peek(constant.HeaderAcceptRanges)))
+	assert.Equal(t, `"6631f680-14"`, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+
+	ctx = serveFile(t, "GetV1Missing", nil)
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+
+	ctx = serveFile(t, "GetV1Embedded", nil)
+	assert.Equal(t, `{"ok":true}`, string(ctx.Response.Body()))
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `inline; filename="______.json"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.json`,
+		string(ctx.Response.Header.Peek(constant.HeaderContentDisposition)))
+
+	ctx = serveFile(t, "GetV1Content", nil)
+	assert.Equal(t, "text/html; charset=utf-8", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "inline", string(ctx.Response.Header.Peek(constant.HeaderContentDisposition)))
+	assert.Empty(t, ctx.Response.Header.Peek(constant.HeaderLastModified))
+}
+
+func TestFileRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"single", map[string]string{"Range": "bytes=2-5"}, 206, "2345", "bytes 2-5/20"},
+		{"open end", map[string]string{"Range": "bytes=15-"}, 206, "fghij", "bytes 15-19/20"},
+		{"suffix", map[string]string{"Range": "bytes=-3"}, 206, "hij", "bytes 17-19/20"},
+		{"end beyond size", map[string]string{"Range": "bytes=18-100"}, 206, "ij", "bytes 18-19/20"},
+		{"unsatisfiable", map[string]string{"Range": "bytes=20-"}, 416, "", "bytes */20"},
+		{"invalid", map[string]string{"Range": "bytes=5-2"}, 416, "", "bytes */20"},
+		{"more than the file", map[string]string{"Range": "bytes=0-,0-"}, 200, "0123456789abcdefghij", ""},
+		{"if-range matches", map[string]string{"Range": "bytes=0-0", "If-Range": fileModTime.Format(http.TimeFormat)},
+			206, "0", "bytes 0-0/20"},
+		{"if-range modified", map[string]string{"Range": "bytes=0-0", "If-Range": fileModTime.Add(-time.Hour).Format(http.TimeFormat)},
+			200, "0123456789abcdefghij", ""},
+		{"if-range etag", map[string]string{"Range": "bytes=0-0", "If-Range": `"6631f680-14"`}, 206, "0", "bytes 0-0/20"},
+		{"if-range other etag", map[string]string{"Range": "bytes=0-0", "If-Range": `"v1"`}, 200, "0123456789abcdefghij", ""},
+		{"if-none-match", map[string]string{"Range": "bytes=0-0", "If-None-Match": `"v1", W/"6631f680-14"`}, 304, "", ""},
+		{"if-modified-since", map[string]string{"If-Modified-Since": fileModTime.Format(http.TimeFormat)}, 304, "", ""},
+		{"modified since", map[string]string{"If-Modified-Since": fileModTime.Add(-time.Hour).Format(http.TimeFormat)},
+			200, "0123456789abcdefghij", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := serveFile(t, "GetV1Report", tt.header)
+			assert.Equal(t, tt.status, ctx.Response.StatusCode())
+			assert.Equal(t, tt.contentRange, string(ctx.Response.Header.Peek(constant.HeaderContentRange)))
+			if tt.status != 416 {
+				assert.Equal(t, tt.body, string(ctx.Response.Body()))
+			}
+		})
+	}
+}
+
+func TestFileMultiRange(t *testing.T) {
+	ctx := serveFile(t, "GetV1Report", map[string]string{"Range": "bytes=0-1, 10-12"})
+	assert.Equal(t, fasthttp.StatusPartialContent, ctx.Response.StatusCode())
+	mediaType, params, err := mime.ParseMediaType(string(ctx.Response.Header.ContentType()))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+	body := ctx.Response.Body()
+	assert.Equal(t, ctx.Response.Header.ContentLength(), len(body))
+
+	mr := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(part)
+		assert.Equal(t, "text/plain; charset=utf-8", part.Header.Get(constant.HeaderContentType))
+		parts = append(parts, part.Header.Get(constant.HeaderContentRange)+" "+string(data))
+	}
+	assert.Equal(t, []string{"bytes 0-1/20 01", "bytes 10-12/20 abc"}, parts)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
 	"github.com/fengyuan-liang/jet-web-fasthttp/core/router"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/view"
 	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
 	"github.com/valyala/fasthttp"
 	"runtime/debug"
@@ -52,3 +53,14 @@ func RecoverJetMiddleware(next router.IJetRouter) (router.IJetRouter, error) {
 		next.ServeHTTP(ctx)
 	}), nil
 }
+
+// CSRFJetMiddleware rejects the unsafe requests without the csrf token rendered by the views, see view.VerifyCSRF
+func CSRFJetMiddleware(next router.IJetRouter) (router.IJetRouter, error) {
+	return JetHandlerFunc(func(ctx *fasthttp.RequestCtx) {
+		if err := view.VerifyCSRF(ctx); err != nil {
+			handler.FailErrorHandler(ctx, err)
+			return
+		}
+		next.ServeHTTP(ctx)
+	}), nil
+}
@@ -0,0 +1,92 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"net/http"
+)
+
+// Response is a return value carrying the status, headers and cookies of the response along with the body, like
+//
+//	func (c *UserController) PostV1User(req *CreateUserReq) (jet.Response[*User], error) {
+//		user, err := c.svc.Create(req)
+//		if err != nil {
+//			return jet.Response[*User]{}, err
+//		}
+//		return jet.Created("/v1/user/"+user.Id, user), nil
+//	}
+//
+// The body is encoded like any other return value, a nil body writes none.
+type Response[T any] struct {
+	Status  int // 200 if it is 0
+	Headers http.Header
+	Cookies []*fasthttp.Cookie
+	Body    T
+}
+
+// NewResponse returns a Response of the status and body
+func NewResponse[T any](status int, body T) Response[T] {
+	return Response[T]{Status: status, Body: body}
+}
+
+// OK returns a 200 Response of the body
+func OK[T any](body T) Response[T] {
+	return NewResponse(constant.StatusOK, body)
+}
+
+// Created returns a 201 Response of the body with the Location of the created resource
+func Created[T any](location string, body T) Response[T] {
+	return NewResponse(constant.StatusCreated, body).WithHeader(constant.HeaderLocation, location)
+}
+
+// NoContent returns a 204 Response without a body
+func NoContent() Response[any] {
+	return Response[any]{Status: constant.StatusNoContent}
+}
+
+// WithHeader returns a copy of the Response with the header value added
+func (r Response[T]) WithHeader(key, value string) Response[T] {
+	headers := r.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Add(key, value)
+	r.Headers = headers
+	return r
+}
+
+// WithETag returns a copy of the Response with the ETag of the version tag instead of the one hashing the body,
+// see handler.FormatETag
+func (r Response[T]) WithETag(tag string) Response[T] {
+	return r.WithHeader(constant.HeaderETag, handler.FormatETag(tag))
+}
+
+// WithCookie returns a copy of the Response with the cookie set
+func (r Response[T]) WithCookie(cookie *fasthttp.Cookie) Response[T] {
+	r.Cookies = append(r.Cookies[:len(r.Cookies):len(r.Cookies)], cookie)
+	return r
+}
+
+func (r Response[T]) ResponseBody() any {
+	return r.Body
+}
+
+func (r Response[T]) ApplyResponse(resp *fasthttp.Response) {
+	if r.Status != 0 {
+		resp.SetStatusCode(r.Status)
+	}
+	for key, values := range r.Headers {
+		resp.Header.Del(key)
+		for _, value := range values {
+			resp.Header.Add(key, value)
+		}
+	}
+	for _, cookie := range r.Cookies {
+		resp.Header.SetCookie(cookie)
+	}
+}
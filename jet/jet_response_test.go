@@ -0,0 +1,114 @@
+package jet
+
+import (
+	"encoding/json"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/hook"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"testing"
+)
+
+type createdUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type responseController struct{}
+
+func (c *responseController) PostV1User() (Response[*createdUser], error) {
+	cookie := new(fasthttp.Cookie)
+	cookie.SetKey("session")
+	cookie.SetValue("s1")
+	return Created("/v1/user/7", &createdUser{Id: "7", Name: "jet"}).WithCookie(cookie), nil
+}
+
+func (c *responseController) DeleteV1User() (error, Response[any]) {
+	return nil, NoContent()
+}
+
+func (c *responseController) GetV1Text() Response[string] {
+	return NewResponse(fasthttp.StatusAccepted, "queued").WithHeader(constant.HeaderContentType, "text/x-queue")
+}
+
+func (c *responseController) GetV1User() Response[*createdUser] {
+	return OK(&createdUser{Id: "7", Name: "jet"}).WithETag("7.3")
+}
+
+func serveResponse(t *testing.T, name, method string) *fasthttp.RequestCtx {
+	rcvr := reflect.ValueOf(&responseController{})
+	m, _ := rcvr.Type().MethodByName(name)
+	h, err := handler.HandlerCreator{}.New(&rcvr, &m)
+	assert.NoError(t, err)
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI("/")
+	h.ServeHTTP(ctx, nil)
+	return ctx
+}
+
+func TestResponse(t *testing.T) {
+	ctx := serveResponse(t, "PostV1User", fasthttp.MethodPost)
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Equal(t, "/v1/user/7", string(ctx.Response.Header.Peek(constant.HeaderLocation)))
+	assert.JSONEq(t, `{"id":"7","name":"jet"}`, string(ctx.Response.Body()))
+	cookie := new(fasthttp.Cookie)
+	cookie.SetKey("session")
+	assert.True(t, ctx.Response.Header.Cookie(cookie))
+	assert.Equal(t, "s1", string(cookie.Value()))
+
+	ctx = serveResponse(t, "DeleteV1User", fasthttp.MethodDelete)
+	assert.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Body())
+
+	ctx = serveResponse(t, "GetV1Text", fasthttp.MethodGet)
+	assert.Equal(t, fasthttp.StatusAccepted, ctx.Response.StatusCode())
+	assert.Equal(t, "text/x-queue", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "queued", string(ctx.Response.Body()))
+
+	ctx = serveResponse(t, "GetV1User", fasthttp.MethodGet)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `"7.3"`, string(ctx.Response.Header.Peek(constant.HeaderETag)))
+
+	// a Response is a plain value in unit tests
+	r, _ := (&responseController{}).PostV1User()
+	assert.Equal(t, fasthttp.StatusCreated, r.Status)
+	assert.Equal(t, "jet", r.Body.Name)
+	base := OK("x")
+	_ = base.WithHeader("X-A", "1")
+	assert.Nil(t, base.Headers, "With methods return copies")
+}
+
+type validatedController struct {
+	BaseJetController
+}
+
+type validatedReq struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func (c *validatedController) PostV1Validated(req *validatedReq) (*validatedReq, error) {
+	return req, nil
+}
+
+func TestValidationError(t *testing.T) {
+	rcvr := reflect.ValueOf(&validatedController{})
+	m, _ := rcvr.Type().MethodByName("PostV1Validated")
+	h, err := handler.HandlerCreator{}.New(&rcvr, &m)
+	assert.NoError(t, err)
+	h.AddHook(new(hook.Hook).GenHook(&rcvr))
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.SetContentType("application/json")
+	ctx.Request.SetRequestURI("/")
+	ctx.Request.SetBodyString(`{}`)
+	h.ServeHTTP(ctx, nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+	assert.Equal(t, float64(400), body["code"])
+	assert.Equal(t, "bad request", body["message"])
+	assert.NotEmpty(t, body["data"])
+}
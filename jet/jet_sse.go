@@ -0,0 +1,225 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"bufio"
+	"context"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultKeepAlive is how often a comment is sent on an idle event stream,
+// which keeps proxies from closing it and detects disconnected clients
+const defaultKeepAlive = 15 * time.Second
+
+// Event is a server-sent event
+type Event struct {
+	Id    string        // sent back by the client as the Last-Event-ID header when it reconnects
+	Event string        // the event type, "message" if empty
+	Data  any           // a string or []byte is sent as it is, anything else as json
+	Retry time.Duration // the reconnection time of the client, not sent if 0
+}
+
+// SSE is a return value streaming server-sent events, like
+//
+//	func (c *JobController) GetV1Progress(id param.Path[string]) jet.SSE {
+//		return jet.NewSSE(func(stream *jet.EventStream) error {
+//			for p := range c.jobs.Watch(stream.Context(), id.Get(), stream.LastEventId) {
+//				if err := stream.Send(jet.Event{Id: p.Seq, Data: p}); err != nil {
+//					return err
+//				}
+//			}
+//			return nil
+//		})
+//	}
+//
+// The stream ends when the producer returns or the client disconnects.
+type SSE struct {
+	events    <-chan Event
+	produce   func(stream *EventStream) error
+	keepAlive time.Duration
+	retry     time.Duration
+}
+
+// NewSSE returns an SSE streaming the events sent by f, f runs in its own goroutine
+// and should return once the context of the stream is done.
+func NewSSE(f func(stream *EventStream) error) SSE {
+	return SSE{produce: f, keepAlive: defaultKeepAlive}
+}
+
+// SSEOf returns an SSE streaming the events of the channel until it is closed.
+// The channel is not drained after the client disconnects, use NewSSE to learn about the disconnection.
+func SSEOf(events <-chan Event) SSE {
+	return SSE{events: events, keepAlive: defaultKeepAlive}
+}
+
+// WithKeepAlive returns a copy of the SSE sending a comment after d of idleness, 0 disables it
+func (s SSE) WithKeepAlive(d time.Duration) SSE {
+	s.keepAlive = d
+	return s
+}
+
+// WithRetry returns a copy of the SSE telling the client to reconnect after d once the stream breaks
+func (s SSE) WithRetry(d time.Duration) SSE {
+	s.retry = d
+	return s
+}
+
+// EventStream is the stream of events passed to the producer of NewSSE
+type EventStream struct {
+	LastEventId string // the Last-Event-ID header of a reconnecting client, resume after it
+	ctx         context.Context
+	events      chan<- Event
+}
+
+// Context returns the context done when the client disconnects or the stream ends
+func (s *EventStream) Context() context.Context {
+	return s.ctx
+}
+
+// Send sends the event, blocking until it is written, the error is the one of Context once it is done
+func (s *EventStream) Send(e Event) error {
+	select {
+	case s.events <- e:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s SSE) WriteResponse(ctx *fasthttp.RequestCtx) error {
+	ctx.SetStatusCode(constant.StatusOK)
+	ctx.SetContentType(constant.MIMETextEventStream)
+	ctx.Response.Header.SetServer("JetServer")
+	ctx.Response.Header.Set(constant.HeaderCacheControl, "no-cache")
+	// nginx buffers responses otherwise
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	lastEventId := string(ctx.Request.Header.Peek(constant.HeaderLastEventID))
+	shutdown := doneOf(ctx)
+	// ctx must not be used in the stream writer, which runs after the handler returns
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := s.events
+		if s.produce != nil {
+			ch := make(chan Event)
+			events = ch
+			stream := &EventStream{LastEventId: lastEventId, ctx: streamCtx, events: ch}
+			go func() {
+				defer close(ch)
+				if err := s.produce(stream); err != nil && streamCtx.Err() == nil {
+					jetLog.Errorf("sse: %v", err)
+				}
+			}()
+		}
+		if s.retry > 0 {
+			writeRetry(w, s.retry)
+			_ = w.WriteByte('\n')
+			if w.Flush() != nil {
+				return
+			}
+		}
+		var (
+			keepAlive <-chan time.Time
+			idle      *time.Timer
+		)
+		if s.keepAlive > 0 {
+			idle = time.NewTimer(s.keepAlive)
+			defer idle.Stop()
+			keepAlive = idle.C
+		}
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					jetLog.Errorf("sse: %v", err)
+					return
+				}
+			case <-keepAlive:
+				_, _ = w.WriteString(": keep-alive\n\n")
+			case <-shutdown:
+				return
+			}
+			// a failed flush means the client is gone
+			if w.Flush() != nil {
+				return
+			}
+			// the stream is idle again from the last write
+			if idle != nil {
+				if !idle.Stop() {
+					select {
+					case <-idle.C:
+					default:
+					}
+				}
+				idle.Reset(s.keepAlive)
+			}
+		}
+	})
+	return nil
+}
+
+// writeEvent writes the event in the text/event-stream format, a line of data for each line of Data
+func writeEvent(w *bufio.Writer, e Event) error {
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := utils.ObjToByte(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	if e.Id != "" {
+		writeField(w, "id", e.Id)
+	}
+	if e.Event != "" {
+		writeField(w, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		writeRetry(w, e.Retry)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		writeField(w, "data", line)
+	}
+	_ = w.WriteByte('\n')
+	return nil
+}
+
+// writeField writes a field of a single line, line breaks would start another field
+func writeField(w *bufio.Writer, name, value string) {
+	_, _ = w.WriteString(name)
+	_, _ = w.WriteString(": ")
+	_, _ = w.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(value))
+	_ = w.WriteByte('\n')
+}
+
+func writeRetry(w *bufio.Writer, d time.Duration) {
+	_, _ = w.WriteString("retry: ")
+	_, _ = w.WriteString(strconv.FormatInt(d.Milliseconds(), 10))
+	_ = w.WriteByte('\n')
+}
+
+// doneOf returns the channel closed when the server shuts down,
+// nil for a RequestCtx outside of a server, like in tests
+func doneOf(ctx *fasthttp.RequestCtx) (done <-chan struct{}) {
+	defer func() {
+		_ = recover()
+	}()
+	return ctx.Done()
+}
@@ -0,0 +1,91 @@
+package jet
+
+import (
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.Set(constant.HeaderLastEventID, "1")
+	sse := NewSSE(func(stream *EventStream) error {
+		assert.Equal(t, "1", stream.LastEventId)
+		_ = stream.Send(Event{Id: "2", Event: "progress", Data: map[string]int{"percent": 50}})
+		_ = stream.Send(Event{Data: "line1\nline2", Retry: time.Second})
+		return nil
+	}).WithRetry(3 * time.Second)
+	assert.NoError(t, sse.WriteResponse(ctx))
+	assert.Equal(t, constant.MIMETextEventStream, string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "no-cache", string(ctx.Response.Header.Peek(constant.HeaderCacheControl)))
+	assert.Equal(t, "retry: 3000\n\n"+
+		"id: 2\nevent: progress\ndata: {\"percent\":50}\n\n"+
+		"retry: 1000\ndata: line1\ndata: line2\n\n", string(ctx.Response.Body()))
+}
+
+func TestSSEOf(t *testing.T) {
+	events := make(chan Event, 2)
+	events <- Event{Id: "a\nb", Data: []byte("raw")}
+	close(events)
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, SSEOf(events).WriteResponse(ctx))
+	assert.Equal(t, "id: ab\ndata: raw\n\n", string(ctx.Response.Body()))
+}
+
+func TestSSEKeepAlive(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	sse := NewSSE(func(stream *EventStream) error {
+		time.Sleep(200 * time.Millisecond)
+		return stream.Send(Event{Data: "done"})
+	}).WithKeepAlive(20 * time.Millisecond)
+	assert.NoError(t, sse.WriteResponse(ctx))
+	body := string(ctx.Response.Body())
+	assert.True(t, strings.HasPrefix(body, ": keep-alive\n\n"), body)
+	assert.True(t, strings.HasSuffix(body, "data: done\n\n"), body)
+
+	// events sent far more often than the keep-alive leave no room for a comment,
+	// though they last longer than it in total
+	ctx = new(fasthttp.RequestCtx)
+	sse = NewSSE(func(stream *EventStream) error {
+		for i := 0; i < 60; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if err := stream.Send(Event{Data: "tick"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).WithKeepAlive(500 * time.Millisecond)
+	assert.NoError(t, sse.WriteResponse(ctx))
+	assert.NotContains(t, string(ctx.Response.Body()), "keep-alive")
+}
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestSSEDisconnect(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	stopped := make(chan error, 1)
+	sse := NewSSE(func(stream *EventStream) error {
+		for {
+			if err := stream.Send(Event{Data: "tick"}); err != nil {
+				stopped <- err
+				return err
+			}
+		}
+	})
+	assert.NoError(t, sse.WriteResponse(ctx))
+	assert.Error(t, ctx.Response.BodyWriteTo(brokenWriter{}))
+	select {
+	case err := <-stopped:
+		assert.Equal(t, "context canceled", err.Error())
+	case <-time.After(time.Second):
+		t.Fatal("the producer is not canceled after the client disconnected")
+	}
+}
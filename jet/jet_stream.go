@@ -0,0 +1,244 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"time"
+)
+
+const defaultFlushInterval = 200 * time.Millisecond
+
+// StreamFormat is how the items of a Stream are encoded
+type StreamFormat int
+
+const (
+	NDJSON    StreamFormat = iota // a json value per line, application/x-ndjson
+	JSONArray                     // a single json array, application/json
+	CSV                           // a csv row per item with a header row, text/csv, see utils.CsvMarshal
+)
+
+// Stream is a return value encoding a large result set item by item instead of building the whole body, like
+//
+//	func (c *ExportController) GetV1Orders() jet.Stream[*Order] {
+//		return jet.NewStream(func(ctx context.Context, yield func(*Order) error) error {
+//			rows, err := c.db.QueryContext(ctx, "SELECT ...")
+//			if err != nil {
+//				return err
+//			}
+//			defer rows.Close()
+//			for rows.Next() {
+//				order, err := scanOrder(rows)
+//				if err != nil {
+//					return err
+//				}
+//				if err = yield(order); err != nil {
+//					return err
+//				}
+//			}
+//			return rows.Err()
+//		})
+//	}
+//
+// As the status is sent before the first item, a failure of the producer or of encoding an item aborts the stream:
+// a JSONArray is left unterminated so that it fails to parse, NDJSON ends with a line of {"error":"stream aborted"},
+// or of the message of a *constant.Error returned by the producer, the error itself is only logged,
+// and CSV is cut after the last complete row.
+type Stream[T any] struct {
+	produce       func(ctx context.Context, yield func(T) error) error
+	format        StreamFormat
+	flushInterval time.Duration
+	filename      string
+}
+
+// NewStream returns a Stream of the items yielded by f, f runs in its own goroutine, yield returns an error
+// once the client disconnects, and ctx is done then.
+func NewStream[T any](f func(ctx context.Context, yield func(T) error) error) Stream[T] {
+	return Stream[T]{produce: f, flushInterval: defaultFlushInterval}
+}
+
+// StreamOf returns a Stream of the items of the channel until it is closed.
+// The channel is not drained after the client disconnects, use NewStream to learn about the disconnection.
+func StreamOf[T any](items <-chan T) Stream[T] {
+	return NewStream(func(ctx context.Context, yield func(T) error) error {
+		for item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CSVOf returns a Stream of the rows as a csv attachment of the filename, like
+//
+//	func (c *ReportController) GetV1Report() (jet.Stream[*Row], error) {
+//		rows, err := c.reports.Rows()
+//		return jet.CSVOf("report.csv", rows), err
+//	}
+//
+// Large results should rather be produced by NewStream(...).AsCSV(filename).
+func CSVOf[T any](filename string, rows []T) Stream[T] {
+	return NewStream(func(ctx context.Context, yield func(T) error) error {
+		for _, row := range rows {
+			if err := yield(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).AsCSV(filename)
+}
+
+// AsCSV returns a copy of the Stream encoding the items as csv rows, an attachment of the filename unless it is empty
+func (s Stream[T]) AsCSV(filename string) Stream[T] {
+	s.format, s.filename = CSV, filename
+	return s
+}
+
+// AsJSONArray returns a copy of the Stream encoding the items as a single json array
+func (s Stream[T]) AsJSONArray() Stream[T] {
+	s.format = JSONArray
+	return s
+}
+
+// WithFlushInterval returns a copy of the Stream sending the encoded items at least every d, 200ms by default,
+// 0 sends every item as soon as it is encoded
+func (s Stream[T]) WithFlushInterval(d time.Duration) Stream[T] {
+	s.flushInterval = d
+	return s
+}
+
+func (s Stream[T]) WriteResponse(ctx *fasthttp.RequestCtx) error {
+	ctx.SetStatusCode(constant.StatusOK)
+	switch s.format {
+	case JSONArray:
+		ctx.SetContentType(constant.MIMEApplicationJSONCharsetUTF8)
+	case CSV:
+		ctx.SetContentType(constant.MIMETextCSV + "; charset=utf-8")
+		if s.filename != "" {
+			ctx.Response.Header.Set(constant.HeaderContentDisposition, contentDisposition("attachment", s.filename))
+		}
+	default:
+		ctx.SetContentType(constant.MIMEApplicationNDJSON)
+	}
+	ctx.Response.Header.SetServer("JetServer")
+	shutdown := doneOf(ctx)
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		items := make(chan T)
+		produced := make(chan error, 1)
+		go func() {
+			defer close(items)
+			produced <- s.produce(streamCtx, func(item T) error {
+				select {
+				case items <- item:
+					return nil
+				case <-streamCtx.Done():
+					return streamCtx.Err()
+				}
+			})
+		}()
+		var flush <-chan time.Time
+		if s.flushInterval > 0 {
+			ticker := time.NewTicker(s.flushInterval)
+			defer ticker.Stop()
+			flush = ticker.C
+		}
+		n := 0
+		var csvEncoder *utils.CsvEncoder
+		switch s.format {
+		case JSONArray:
+			_ = w.WriteByte('[')
+		case CSV:
+			csvEncoder = utils.NewCsvEncoder(w)
+			// the header of an empty result, unless T is an interface
+			if typ := reflect.TypeOf((*T)(nil)).Elem(); typ.Kind() != reflect.Interface {
+				if err := csvEncoder.WriteHeader(typ); err != nil {
+					s.abort(w, err)
+					return
+				}
+			}
+		}
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					if csvEncoder != nil {
+						_ = csvEncoder.Flush()
+					}
+					if err := <-produced; err != nil {
+						s.abort(w, err)
+						return
+					}
+					if s.format == JSONArray {
+						_ = w.WriteByte(']')
+					}
+					return
+				}
+				if csvEncoder != nil {
+					if err := csvEncoder.Encode(item); err != nil {
+						_ = csvEncoder.Flush()
+						s.abort(w, err)
+						return
+					}
+					if n++; s.flushInterval == 0 && (csvEncoder.Flush() != nil || w.Flush() != nil) {
+						return
+					}
+					continue
+				}
+				b, err := utils.ObjToByte(item)
+				if err != nil {
+					s.abort(w, err)
+					return
+				}
+				if s.format == JSONArray && n > 0 {
+					_ = w.WriteByte(',')
+				}
+				// a failed write means the client is gone
+				if _, err = w.Write(b); err != nil {
+					return
+				}
+				if s.format == NDJSON {
+					_ = w.WriteByte('\n')
+				}
+				if n++; s.flushInterval == 0 && w.Flush() != nil {
+					return
+				}
+			case <-flush:
+				if csvEncoder != nil && csvEncoder.Flush() != nil {
+					return
+				}
+				if w.Flush() != nil {
+					return
+				}
+			case <-shutdown:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func (s Stream[T]) abort(w *bufio.Writer, err error) {
+	jetLog.Errorf("stream aborted: %v", err)
+	if s.format == NDJSON {
+		// only the message of a *constant.Error is meant for the client, any other error is only logged
+		message := "stream aborted"
+		var e *constant.Error
+		if errors.As(err, &e) {
+			message = e.Message
+		}
+		b, _ := utils.ObjToByte(map[string]string{"error": message})
+		_, _ = w.Write(b)
+		_ = w.WriteByte('\n')
+	}
+}
@@ -0,0 +1,120 @@
+package jet
+
+import (
+	"context"
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+	"time"
+)
+
+type streamRow struct {
+	Id int `json:"id"`
+}
+
+func rows(n int) func(ctx context.Context, yield func(streamRow) error) error {
+	return func(ctx context.Context, yield func(streamRow) error) error {
+		for i := 1; i <= n; i++ {
+			if err := yield(streamRow{Id: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestStream(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, NewStream(rows(3)).WriteResponse(ctx))
+	assert.Equal(t, constant.MIMEApplicationNDJSON, string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, NewStream(rows(3)).AsJSONArray().WithFlushInterval(0).WriteResponse(ctx))
+	assert.Equal(t, constant.MIMEApplicationJSONCharsetUTF8, string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `[{"id":1},{"id":2},{"id":3}]`, string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, NewStream(rows(0)).AsJSONArray().WriteResponse(ctx))
+	assert.Equal(t, `[]`, string(ctx.Response.Body()))
+
+	items := make(chan int, 2)
+	items <- 1
+	items <- 2
+	close(items)
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, StreamOf(items).WriteResponse(ctx))
+	assert.Equal(t, "1\n2\n", string(ctx.Response.Body()))
+}
+
+func TestStreamAbort(t *testing.T) {
+	failing := NewStream(func(ctx context.Context, yield func(any) error) error {
+		_ = yield(1)
+		return errors.New("db gone")
+	})
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, failing.WriteResponse(ctx))
+	assert.Equal(t, "1\n{\"error\":\"stream aborted\"}\n", string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, NewStream(func(ctx context.Context, yield func(any) error) error {
+		return constant.NewError(constant.StatusServiceUnavailable, "export unavailable")
+	}).WriteResponse(ctx))
+	assert.Equal(t, "{\"error\":\"export unavailable\"}\n", string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, failing.AsJSONArray().WriteResponse(ctx))
+	assert.Equal(t, "[1", string(ctx.Response.Body()))
+
+	unencodable := NewStream(func(ctx context.Context, yield func(any) error) error {
+		_ = yield(1)
+		return yield(make(chan int))
+	})
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, unencodable.AsJSONArray().WriteResponse(ctx))
+	assert.Equal(t, "[1", string(ctx.Response.Body()))
+}
+
+func TestStreamDisconnect(t *testing.T) {
+	stopped := make(chan error, 1)
+	endless := NewStream(func(ctx context.Context, yield func(int) error) error {
+		for i := 0; ; i++ {
+			if err := yield(i); err != nil {
+				stopped <- err
+				return err
+			}
+		}
+	})
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, endless.WriteResponse(ctx))
+	assert.Error(t, ctx.Response.BodyWriteTo(brokenWriter{}))
+	select {
+	case err := <-stopped:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("the producer is not canceled after the client disconnected")
+	}
+}
+
+func TestStreamCSV(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, CSVOf("报表.csv", []*streamRow{{Id: 1}, {Id: 2}}).WriteResponse(ctx))
+	assert.Equal(t, "text/csv; charset=utf-8", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `attachment; filename="______.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv`,
+		string(ctx.Response.Header.Peek(constant.HeaderContentDisposition)))
+	assert.Equal(t, "id\n1\n2\n", string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, CSVOf("empty.csv", []streamRow{}).WriteResponse(ctx))
+	assert.Equal(t, "id\n", string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, NewStream(func(ctx context.Context, yield func(streamRow) error) error {
+		_ = yield(streamRow{Id: 1})
+		return errors.New("db gone")
+	}).AsCSV("").WriteResponse(ctx))
+	assert.Empty(t, ctx.Response.Header.Peek(constant.HeaderContentDisposition))
+	assert.Equal(t, "id\n1\n", string(ctx.Response.Body()))
+}
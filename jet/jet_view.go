@@ -0,0 +1,82 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"bytes"
+	"errors"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/view"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+)
+
+var viewEngine *view.Engine
+
+// UseViews renders the View return values by the templates of the config, like
+//
+//	//go:embed views
+//	var views embed.FS
+//
+//	jet.UseViews(view.Config{FS: views, Layout: "layouts/main", Reload: dev})
+//
+// It must be called before the server starts.
+func UseViews(config view.Config) error {
+	engine, err := view.New(config)
+	if err != nil {
+		return err
+	}
+	viewEngine = engine
+	return nil
+}
+
+// View is a return value rendering the html template of the name with the model, like
+//
+//	func (c *DashboardController) GetUsers() (jet.View, error) {
+//		users, err := c.users.List()
+//		return jet.Render("users/list", users), err
+//	}
+type View struct {
+	Name   string
+	Model  any
+	Layout string // the layout of view.Config if it is empty, "-" for none
+	Status int    // 200 if it is 0
+}
+
+// Render returns a View of the template name with the model
+func Render(name string, model any) View {
+	return View{Name: name, Model: model}
+}
+
+// WithLayout returns a copy of the View wrapped by the layout, "-" for none
+func (v View) WithLayout(layout string) View {
+	v.Layout = layout
+	return v
+}
+
+// WithStatus returns a copy of the View of the status
+func (v View) WithStatus(status int) View {
+	v.Status = status
+	return v
+}
+
+func (v View) WriteResponse(ctx *fasthttp.RequestCtx) error {
+	if viewEngine == nil {
+		return errors.New("jet: no views, see jet.UseViews")
+	}
+	// rendered into a buffer so that a failing template results in a clean 500
+	var buf bytes.Buffer
+	if err := viewEngine.Render(&buf, v.Name, v.Layout, v.Model, view.RequestFuncs(ctx)); err != nil {
+		return err
+	}
+	status := v.Status
+	if status == 0 {
+		status = constant.StatusOK
+	}
+	ctx.SetStatusCode(status)
+	ctx.Response.Header.SetServer("JetServer")
+	ctx.SetContentType(constant.MIMETextHTMLCharsetUTF8)
+	ctx.SetBody(buf.Bytes())
+	return nil
+}
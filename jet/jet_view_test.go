@@ -0,0 +1,36 @@
+package jet
+
+import (
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/view"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+	"testing/fstest"
+)
+
+func TestView(t *testing.T) {
+	defer func() { viewEngine = nil }()
+	assert.Error(t, Render("index", nil).WriteResponse(new(fasthttp.RequestCtx)))
+
+	assert.NoError(t, UseViews(view.Config{FS: fstest.MapFS{
+		"layout.html": {Data: []byte(`<body>{{ template "content" . }}</body>`)},
+		"index.html":  {Data: []byte(`<p>{{ . }}</p>`)},
+		"broken.html": {Data: []byte(`{{ .Missing.Field }}`)},
+	}, Layout: "layout"}))
+
+	ctx := new(fasthttp.RequestCtx)
+	assert.NoError(t, Render("index", "<jet>").WriteResponse(ctx))
+	assert.Equal(t, constant.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, constant.MIMETextHTMLCharsetUTF8, string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `<body><p>&lt;jet&gt;</p></body>`, string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.NoError(t, Render("index", "gone").WithLayout("-").WithStatus(constant.StatusNotFound).WriteResponse(ctx))
+	assert.Equal(t, constant.StatusNotFound, ctx.Response.StatusCode())
+	assert.Equal(t, `<p>gone</p>`, string(ctx.Response.Body()))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.Error(t, Render("broken", 1).WriteResponse(ctx))
+	assert.Empty(t, ctx.Response.Body())
+}
@@ -0,0 +1,182 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/valyala/fasthttp"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// websocketGUID is appended to the Sec-WebSocket-Key to compute the Sec-WebSocket-Accept, RFC 6455, section 1.3
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const defaultMaxMessageSize = 1 << 20
+
+// WebSocket is a return value upgrading the request to a WebSocket connection served by f, like
+//
+//	func (c *ChatController) GetV1Chat(ctx Ctx, room param.Query[string]) jet.WebSocket {
+//		user := c.auth.UserOf(ctx)
+//		return jet.NewWebSocket(func(conn *jet.WebSocketConn) error {
+//			for {
+//				_, msg, err := conn.ReadMessage()
+//				if err != nil {
+//					return err
+//				}
+//				c.rooms.Broadcast(room.Get(), user, msg)
+//			}
+//		})
+//	}
+//
+// The upgrade request is an ordinary GET request, it goes through the middlewares, hooks and parameter binding
+// of the route, and the method can refuse the upgrade by returning an error.
+// f runs after the 101 response is sent, when the Ctx of the request is no longer usable,
+// so it should capture what it needs from the request beforehand.
+// The connection is closed when f returns, with 1000 for a nil error and 1011 otherwise.
+type WebSocket struct {
+	serve          func(conn *WebSocketConn) error
+	maxMessageSize int64
+	pingInterval   time.Duration
+	compression    bool
+	checkOrigin    func(ctx *fasthttp.RequestCtx) bool
+}
+
+// NewWebSocket returns a WebSocket served by f
+func NewWebSocket(f func(conn *WebSocketConn) error) WebSocket {
+	return WebSocket{serve: f, maxMessageSize: defaultMaxMessageSize, checkOrigin: sameOrigin}
+}
+
+// WithMaxMessageSize returns a copy of the WebSocket closing the connection with 1009
+// when a message is larger than n bytes, 1MB by default
+func (ws WebSocket) WithMaxMessageSize(n int64) WebSocket {
+	ws.maxMessageSize = n
+	return ws
+}
+
+// WithPingInterval returns a copy of the WebSocket sending a ping every d, the connection is closed
+// if nothing is received for twice d. The pongs are only received while the connection is read.
+func (ws WebSocket) WithPingInterval(d time.Duration) WebSocket {
+	ws.pingInterval = d
+	return ws
+}
+
+// WithCompression returns a copy of the WebSocket compressing the messages with permessage-deflate
+// of RFC 7692 if the client offers it
+func (ws WebSocket) WithCompression() WebSocket {
+	ws.compression = true
+	return ws
+}
+
+// WithCheckOrigin returns a copy of the WebSocket accepting the upgrade if f returns true, like
+//
+//	ws.WithCheckOrigin(func(ctx *fasthttp.RequestCtx) bool { return true })
+//
+// By default a request with an Origin header is only accepted from the same host, as browsers
+// do not apply the same-origin policy to WebSockets.
+func (ws WebSocket) WithCheckOrigin(f func(ctx *fasthttp.RequestCtx) bool) WebSocket {
+	ws.checkOrigin = f
+	return ws
+}
+
+func sameOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := string(ctx.Request.Header.Peek(constant.HeaderOrigin))
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, string(ctx.Host()))
+}
+
+func (ws WebSocket) WriteResponse(ctx *fasthttp.RequestCtx) error {
+	header := &ctx.Request.Header
+	if !ctx.IsGet() || !headerContainsToken(string(header.Peek(constant.HeaderConnection)), "upgrade") ||
+		!headerContainsToken(string(header.Peek(constant.HeaderUpgrade)), "websocket") {
+		return constant.NewError(constant.StatusBadRequest, "websocket: not a websocket upgrade request")
+	}
+	if string(header.Peek(constant.HeaderSecWebSocketVersion)) != "13" {
+		ctx.Response.Header.Set(constant.HeaderSecWebSocketVersion, "13")
+		return constant.NewError(constant.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := strings.TrimSpace(string(header.Peek(constant.HeaderSecWebSocketKey)))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return constant.NewError(constant.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	if ws.checkOrigin != nil && !ws.checkOrigin(ctx) {
+		return constant.NewError(constant.StatusForbidden, "websocket: origin not allowed")
+	}
+	if ws.serve == nil {
+		return errors.New("websocket: no handler")
+	}
+	compress := ws.compression && acceptDeflate(string(header.Peek(constant.HeaderSecWebSocketExtensions)))
+
+	ctx.SetStatusCode(constant.StatusSwitchingProtocols)
+	ctx.Response.Header.Set(constant.HeaderUpgrade, "websocket")
+	ctx.Response.Header.Set(constant.HeaderConnection, "Upgrade")
+	ctx.Response.Header.Set(constant.HeaderSecWebSocketAccept, acceptKeyOf(key))
+	if compress {
+		ctx.Response.Header.Set(constant.HeaderSecWebSocketExtensions,
+			"permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+	ctx.Hijack(func(nc net.Conn) {
+		conn := newWebSocketConn(nc, ws.maxMessageSize, ws.pingInterval, compress)
+		conn.finish(ws.run(conn))
+	})
+	return nil
+}
+
+// run calls serve, recovering a panic as an error
+func (ws WebSocket) run(conn *WebSocketConn) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("websocket: panic: %v", r)
+		}
+	}()
+	return ws.serve(conn)
+}
+
+func acceptKeyOf(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken reports whether the comma separated header contains the token, case-insensitively
+func headerContainsToken(header, token string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptDeflate reports whether one of the offers of the Sec-WebSocket-Extensions header is a permessage-deflate
+// the server can answer without context takeover, compress/flate always uses a window of 15 bits.
+func acceptDeflate(extensions string) bool {
+	for _, offer := range strings.Split(extensions, ",") {
+		params := strings.Split(offer, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "permessage-deflate") {
+			continue
+		}
+		ok := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "server_max_window_bits") && strings.Trim(value, `"`) != "15" {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,421 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/utils"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a WebSocket data message
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// the opcodes of RFC 6455, section 5.2
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// the status codes of a close frame, RFC 6455, section 7.4.1
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+const (
+	writeTimeout = 10 * time.Second
+	// closeTimeout is how long the close frame of the client is waited for after sending one
+	closeTimeout = 5 * time.Second
+)
+
+// deflateTail is removed from every compressed message and added back before decompressing, RFC 7692, section 7.2,
+// followed by an empty final block so that the decompressor ends cleanly
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// ErrWebSocketClosed is returned by writing to a connection after the close frame is sent
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by ReadMessage when the connection is closed by a close frame, or for a protocol error
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+// WebSocketConn is an upgraded WebSocket connection, one goroutine may read while others write
+type WebSocketConn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	maxMessageSize int64
+	pingInterval   time.Duration
+	compress       bool
+
+	writeLock sync.Mutex
+	closeSent bool
+	// closeReceived is set by the reading goroutine and read by finish in the serving one, accessed atomically
+	closeReceived int32
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+func newWebSocketConn(nc net.Conn, maxMessageSize int64, pingInterval time.Duration, compress bool) *WebSocketConn {
+	c := &WebSocketConn{
+		conn:           nc,
+		br:             bufio.NewReader(nc),
+		maxMessageSize: maxMessageSize,
+		pingInterval:   pingInterval,
+		compress:       compress,
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	_ = nc.SetDeadline(time.Time{})
+	if pingInterval > 0 {
+		go c.ping()
+	}
+	return c
+}
+
+// Context returns the context done when the function serving the connection returns,
+// like for the subscriptions of a hub.Hub
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// RemoteAddr returns the address of the client
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage reads the next data message, answering pings and the close handshake on the way.
+// The error is a *CloseError once the client closes the connection.
+func (c *WebSocketConn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ        MessageType
+		compressed bool
+		message    []byte
+	)
+	for {
+		if c.pingInterval > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+		}
+		fin, rsv1, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+		switch op {
+		case opPing:
+			if err = c.writeFrame(opPong, false, payload); err != nil && err != ErrWebSocketClosed {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.closeReceivedWith(payload)
+		case opText, opBinary:
+			if typ != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expecting a continuation frame")
+			}
+			typ, compressed = MessageType(op), rsv1
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			if rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "rsv1 set on a continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", op))
+		}
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "")
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if compressed {
+			if message, err = c.inflate(message); err != nil {
+				return 0, nil, err
+			}
+		}
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+		}
+		return typ, message, nil
+	}
+}
+
+// ReadJSON reads the next message as json into v
+func (c *WebSocketConn) ReadJSON(v any) error {
+	_, message, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return utils.ByteToObj(message, v)
+}
+
+// WriteMessage writes a data message in a single frame
+func (c *WebSocketConn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+	if !c.compress {
+		return c.writeFrame(byte(typ), false, data)
+	}
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := fw.Flush(); err != nil {
+		return err
+	}
+	return c.writeFrame(byte(typ), true, bytes.TrimSuffix(buf.Bytes(), deflateTail[:4]))
+}
+
+// WriteJSON writes v as a json text message
+func (c *WebSocketConn) WriteJSON(v any) error {
+	data, err := utils.ObjToByte(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close starts the close handshake, ReadMessage returns the *CloseError of the reply of the client.
+// The connection itself is closed when the function serving it returns.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	return c.writeClose(code, reason)
+}
+
+// readFrame reads a frame of RFC 6455, section 5.2, the frames of a client must be masked
+func (c *WebSocketConn) readFrame() (fin, rsv1 bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin, rsv1, op = head[0]&0x80 != 0, head[0]&0x40 != 0, head[0]&0x0f
+	if head[0]&0x30 != 0 || (rsv1 && (!c.compress || op >= opClose)) {
+		err = &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+		return
+	}
+	if head[1]&0x80 == 0 {
+		err = &CloseError{Code: CloseProtocolError, Reason: "unmasked client frame"}
+		return
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (n > 125 || !fin) {
+		err = &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+		return
+	}
+	if n > uint64(c.maxMessageSize) {
+		err = &CloseError{Code: CloseMessageTooBig}
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i&3]
+	}
+	return
+}
+
+// writeFrame writes a single unmasked frame, a close frame is the last one written
+func (c *WebSocketConn) writeFrame(op byte, rsv1 bool, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+	frame := make([]byte, 10+len(payload))
+	frame[0] = 0x80 | op
+	if rsv1 {
+		frame[0] |= 0x40
+	}
+	n := 2
+	switch size := len(payload); {
+	case size <= 125:
+		frame[1] = byte(size)
+	case size <= 0xffff:
+		frame[1] = 126
+		binary.BigEndian.PutUint16(frame[2:], uint16(size))
+		n += 2
+	default:
+		frame[1] = 127
+		binary.BigEndian.PutUint64(frame[2:], uint64(size))
+		n += 8
+	}
+	n += copy(frame[n:], payload)
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame[:n])
+	return err
+}
+
+func (c *WebSocketConn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	// the payload of a control frame is at most 125 bytes, cut on a rune boundary as the reason must be utf-8
+	if len(reason) > 123 {
+		n := 123
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+	return c.writeFrame(opClose, false, append(payload, reason...))
+}
+
+// closeReceivedWith replies the close frame of the client with the same code, RFC 6455, section 5.5.1
+func (c *WebSocketConn) closeReceivedWith(payload []byte) error {
+	atomic.StoreInt32(&c.closeReceived, 1)
+	e := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		e.Code, e.Reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(e.Code) || !utf8.ValidString(e.Reason) {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+	}
+	if e.Code == CloseNoStatusReceived {
+		_ = c.writeFrame(opClose, false, nil)
+	} else {
+		_ = c.writeClose(e.Code, "")
+	}
+	return e
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != CloseNoStatusReceived && code != 1006
+}
+
+// readFailed turns a protocol error of readFrame into a close frame
+func (c *WebSocketConn) readFailed(err error) error {
+	var e *CloseError
+	if errors.As(err, &e) {
+		return c.fail(e.Code, e.Reason)
+	}
+	return err
+}
+
+// fail closes the connection for a protocol error of the client
+func (c *WebSocketConn) fail(code int, reason string) error {
+	_ = c.writeClose(code, reason)
+	atomic.StoreInt32(&c.closeReceived, 1) // no more frames are read from a broken client
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// inflate decompresses a message of permessage-deflate
+func (c *WebSocketConn) inflate(message []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(message), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+	data, err := io.ReadAll(io.LimitReader(fr, c.maxMessageSize+1))
+	if err != nil {
+		return nil, c.fail(CloseInvalidPayload, "invalid compressed message")
+	}
+	if int64(len(data)) > c.maxMessageSize {
+		return nil, c.fail(CloseMessageTooBig, "")
+	}
+	return data, nil
+}
+
+func (c *WebSocketConn) ping() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.writeFrame(opPing, false, nil) != nil {
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// finish ends the connection after the function serving it returns with err,
+// waiting for the close frame of the client unless it has been received
+func (c *WebSocketConn) finish(err error) {
+	c.cancel()
+	code := CloseNormalClosure
+	var e *CloseError
+	if err != nil && !errors.As(err, &e) && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		jetLog.Errorf("websocket: %v", err)
+		code = CloseInternalError
+	}
+	_ = c.writeClose(code, "")
+	if atomic.LoadInt32(&c.closeReceived) == 1 {
+		return
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		_, _, op, _, err := c.readFrame()
+		if err != nil || op == opClose {
+			return
+		}
+	}
+}
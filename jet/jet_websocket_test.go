@@ -0,0 +1,191 @@
+package jet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"github.com/fengyuan-liang/jet-web-fasthttp/core/handler"
+	"github.com/fengyuan-liang/jet-web-fasthttp/pkg/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type websocketController struct{}
+
+func (c *websocketController) GetV1Echo(ctx Ctx) WebSocket {
+	user := string(ctx.Request().Header.Peek("X-User"))
+	return NewWebSocket(func(conn *WebSocketConn) error {
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if string(msg) == "bye" {
+				return conn.Close(CloseNormalClosure, "bye "+user)
+			}
+			if err = conn.WriteMessage(typ, append([]byte(user+":"), msg...)); err != nil {
+				return err
+			}
+		}
+	}).WithMaxMessageSize(16).WithCompression()
+}
+
+func serveWebSocket(t *testing.T) *fasthttputil.InmemoryListener {
+	rcvr := reflect.ValueOf(&websocketController{})
+	m, _ := rcvr.Type().MethodByName("GetV1Echo")
+	h, err := handler.HandlerCreator{}.New(&rcvr, &m)
+	assert.NoError(t, err)
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		_ = (&fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) { h.ServeHTTP(ctx, nil) }}).Serve(ln)
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln
+}
+
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, ln *fasthttputil.InmemoryListener, header string) (*wsClient, *http.Response) {
+	conn, err := ln.Dial()
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("GET /v1/echo HTTP/1.1\r\nHost: example.com\r\nX-User: jet\r\n" + header + "\r\n"))
+	assert.NoError(t, err)
+	c := &wsClient{conn: conn, br: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(c.br, nil)
+	assert.NoError(t, err)
+	return c, resp
+}
+
+const upgradeHeader = "Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+
+func (c *wsClient) write(head byte, payload []byte) {
+	frame := []byte{head, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^frame[2+i&3])
+	}
+	_, _ = c.conn.Write(frame)
+}
+
+func (c *wsClient) read() (head byte, payload []byte) {
+	var h [2]byte
+	_, _ = io.ReadFull(c.br, h[:])
+	payload = make([]byte, h[1]&0x7f)
+	_, _ = io.ReadFull(c.br, payload)
+	return h[0], payload
+}
+
+func TestWebSocket(t *testing.T) {
+	ln := serveWebSocket(t)
+	c, resp := dialWebSocket(t, ln, upgradeHeader)
+	assert.Equal(t, fasthttp.StatusSwitchingProtocols, resp.StatusCode)
+	// the example of RFC 6455, section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get(constant.HeaderSecWebSocketAccept))
+	assert.Empty(t, resp.Header.Get(constant.HeaderSecWebSocketExtensions))
+
+	// a fragmented text message with a ping in between
+	c.write(opText, []byte("hel"))
+	c.write(0x80|opPing, []byte("p"))
+	c.write(0x80|opContinuation, []byte("lo"))
+	head, payload := c.read()
+	assert.Equal(t, byte(0x80|opPong), head)
+	assert.Equal(t, "p", string(payload))
+	head, payload = c.read()
+	assert.Equal(t, byte(0x80|opText), head)
+	assert.Equal(t, "jet:hello", string(payload))
+
+	// the close handshake started by the server
+	c.write(0x80|opText, []byte("bye"))
+	head, payload = c.read()
+	assert.Equal(t, byte(0x80|opClose), head)
+	assert.Equal(t, CloseNormalClosure, int(binary.BigEndian.Uint16(payload)))
+	assert.Equal(t, "bye jet", string(payload[2:]))
+	c.write(0x80|opClose, payload[:2])
+	_, err := c.br.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	ln := serveWebSocket(t)
+	tests := []struct {
+		name  string
+		frame []byte
+		code  int
+	}{
+		{"unmasked", []byte{0x80 | opText, 1, 'a'}, CloseProtocolError},
+		{"too big", []byte{0x80 | opBinary, 0x80 | 17, 0, 0, 0, 0}, CloseMessageTooBig},
+		{"invalid utf-8", []byte{0x80 | opText, 0x80 | 1, 0, 0, 0, 0, 0xff}, CloseInvalidPayload},
+		{"unknown opcode", []byte{0x80 | 0x3, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := dialWebSocket(t, ln, upgradeHeader)
+			_, _ = c.conn.Write(tt.frame)
+			head, payload := c.read()
+			assert.Equal(t, byte(0x80|opClose), head)
+			assert.Equal(t, tt.code, int(binary.BigEndian.Uint16(payload)))
+		})
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	ln := serveWebSocket(t)
+	c, resp := dialWebSocket(t, ln, upgradeHeader+"Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits\r\n")
+	assert.Equal(t, "permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		resp.Header.Get(constant.HeaderSecWebSocketExtensions))
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = fw.Write([]byte("zip"))
+	_ = fw.Flush()
+	c.write(0xc0|opText, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+	head, payload := c.read()
+	assert.Equal(t, byte(0xc0|opText), head)
+	data, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail))))
+	assert.NoError(t, err)
+	assert.Equal(t, "jet:zip", string(data))
+}
+
+func TestWebSocketUpgradeErrors(t *testing.T) {
+	ln := serveWebSocket(t)
+	_, resp := dialWebSocket(t, ln, "")
+	assert.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode)
+
+	_, resp = dialWebSocket(t, ln, strings.Replace(upgradeHeader, "13", "8", 1))
+	assert.Equal(t, fasthttp.StatusUpgradeRequired, resp.StatusCode)
+	assert.Equal(t, "13", resp.Header.Get(constant.HeaderSecWebSocketVersion))
+
+	_, resp = dialWebSocket(t, ln, upgradeHeader+"Origin: https://evil.com\r\n")
+	assert.Equal(t, fasthttp.StatusForbidden, resp.StatusCode)
+
+	_, resp = dialWebSocket(t, ln, upgradeHeader+"Origin: https://example.com\r\n")
+	assert.Equal(t, fasthttp.StatusSwitchingProtocols, resp.StatusCode)
+}
+
+func TestWebSocketCloseReason(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	c := newWebSocketConn(server, 1<<10, 0, false)
+	go func() { _ = c.writeClose(CloseNormalClosure, strings.Repeat("é", 100)) }()
+	head := make([]byte, 2)
+	_, err := io.ReadFull(client, head)
+	assert.NoError(t, err)
+	payload := make([]byte, head[1])
+	_, err = io.ReadFull(client, payload)
+	assert.NoError(t, err)
+	reason := payload[2:]
+	assert.Len(t, reason, 122)
+	assert.True(t, utf8.Valid(reason))
+}
@@ -31,6 +31,14 @@ const (
 	MIMEApplicationForm       = "application/x-www-form-urlencoded"
 	MIMEOctetStream           = "application/octet-stream"
 	MIMEMultipartForm         = "multipart/form-data"
+	MIMEApplicationMergePatch = "application/merge-patch+json" // RFC 7396
+	MIMEApplicationJSONPatch  = "application/json-patch+json"  // RFC 6902
+	MIMEApplicationMsgpack    = "application/msgpack"
+	MIMEApplicationCBOR       = "application/cbor"         // RFC 8949
+	MIMEApplicationProblem    = "application/problem+json" // RFC 7807
+	MIMETextEventStream       = "text/event-stream"
+	MIMEApplicationNDJSON     = "application/x-ndjson"
+	MIMETextCSV               = "text/csv" // RFC 4180
 
 	MIMETextXMLCharsetUTF8         = "text/xml; charset=utf-8"
 	MIMETextHTMLCharsetUTF8        = "text/html; charset=utf-8"
@@ -118,8 +126,13 @@ const (
 
 // Error represents an error that occurred while handling a request.
 type Error struct {
-	Code    int    `json:"code"`
+	Code    int    `json:"code"` // HTTP status code of the response
 	Message string `json:"message"`
+	// Reason is an application defined error code, like USER_NOT_FOUND
+	Reason string `json:"reason,omitempty"`
+	// Details carries more about the error, like the fields failing validation
+	Details any `json:"details,omitempty"`
+	cause   error
 }
 
 // Error makes it compatible with the `error` interface.
@@ -127,6 +140,37 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// StatusCode returns the HTTP status code of the response
+func (e *Error) StatusCode() int {
+	return e.Code
+}
+
+// Unwrap returns the error wrapped by Wrap
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithReason returns a copy of the error with the application defined error code
+func (e *Error) WithReason(reason string) *Error {
+	err := *e
+	err.Reason = reason
+	return &err
+}
+
+// WithDetails returns a copy of the error with the details
+func (e *Error) WithDetails(details any) *Error {
+	err := *e
+	err.Details = details
+	return &err
+}
+
+// Wrap returns a copy of the error wrapping cause, which is kept for errors.Is and errors.As but not rendered
+func (e *Error) Wrap(cause error) *Error {
+	err := *e
+	err.cause = cause
+	return &err
+}
+
 // NewError creates a new Error instance with an optional message
 func NewError(code int, message ...string) *Error {
 	err := &Error{
@@ -77,6 +77,11 @@ func (r *ErrorInfo) Cause() error {
 	return r.Err
 }
 
+// Unwrap lets errors.Is and errors.As see the wrapped error, like the status of a *constant.Error
+func (r *ErrorInfo) Unwrap() error {
+	return r.Err
+}
+
 func (r *ErrorInfo) Error() string {
 	return r.Err.Error()
 }
@@ -0,0 +1,405 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// binaryWriter writes the items of a binary codec, values are walked by appendBinary
+type binaryWriter interface {
+	name() string
+	appendNil(b []byte) []byte
+	appendBool(b []byte, v bool) []byte
+	appendInt(b []byte, i int64) []byte
+	appendUint(b []byte, u uint64) []byte
+	appendFloat32(b []byte, f float32) []byte
+	appendFloat64(b []byte, f float64) []byte
+	appendString(b []byte, s string) []byte
+	appendBytes(b []byte, p []byte) []byte
+	appendArrayHead(b []byte, n int) []byte
+	appendMapHead(b []byte, n int) []byte
+}
+
+var typeOfJsonMarshaler = reflect.TypeOf((*interface{ MarshalJSON() ([]byte, error) })(nil)).Elem()
+
+// appendBinary writes v by reflection, shaped like the json of ObjToByte: the fields are named by the `json` tags,
+// a json.Marshaler is written as the value of its json and an encoding.TextMarshaler as a string,
+// but []byte is a byte string instead of base64 and numbers keep their type.
+func appendBinary(w binaryWriter, b []byte, v reflect.Value, depth int) ([]byte, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("%s: nested too deep", w.name())
+	}
+	if !v.IsValid() {
+		return w.appendNil(b), nil
+	}
+	if m, ok := marshalerOf(v, typeOfJsonMarshaler); ok {
+		if m == nil {
+			return w.appendNil(b), nil
+		}
+		tree, err := jsonTreeOf(m)
+		if err != nil {
+			return nil, err
+		}
+		return appendBinaryTree(w, b, tree)
+	}
+	if m, ok := marshalerOf(v, typeOfTextMarshaler); ok {
+		if m == nil {
+			return w.appendNil(b), nil
+		}
+		text, err := m.(interface{ MarshalText() ([]byte, error) }).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return w.appendString(b, string(text)), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return w.appendBool(b, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return w.appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return w.appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return w.appendFloat32(b, float32(v.Float())), nil
+	case reflect.Float64:
+		return w.appendFloat64(b, v.Float()), nil
+	case reflect.String:
+		return w.appendString(b, v.String()), nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return w.appendNil(b), nil
+		}
+		return appendBinary(w, b, v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			return w.appendNil(b), nil
+		}
+		if isByteSlice(v.Type()) {
+			return w.appendBytes(b, v.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		b = w.appendArrayHead(b, v.Len())
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendBinary(w, b, v.Index(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		return appendBinaryMap(w, b, v, depth)
+	case reflect.Struct:
+		return appendBinaryStruct(w, b, v, depth)
+	}
+	return nil, fmt.Errorf("%s: unsupported type %s", w.name(), v.Type())
+}
+
+// marshalerOf returns v as the marshaler interface typ like encoding/json calls it, on the address
+// of an addressable value too, and a nil marshaler for a nil pointer
+func marshalerOf(v reflect.Value, typ reflect.Type) (interface{}, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(typ) {
+		return v.Addr().Interface(), true
+	}
+	if !v.Type().Implements(typ) {
+		return nil, false
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, true
+	}
+	return v.Interface(), true
+}
+
+// isByteSlice reports whether json writes the slice type as base64, which the binary codecs write as bytes
+func isByteSlice(typ reflect.Type) bool {
+	if typ.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	p := reflect.PtrTo(typ.Elem())
+	return !p.Implements(typeOfJsonMarshaler) && !p.Implements(typeOfTextMarshaler)
+}
+
+// appendBinaryMap writes a map with the keys json writes, sorted
+func appendBinaryMap(w binaryWriter, b []byte, v reflect.Value, depth int) ([]byte, error) {
+	if v.IsNil() {
+		return w.appendNil(b), nil
+	}
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		key, err := mapKeyOf(w, it.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: key, value: it.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	b = w.appendMapHead(b, len(entries))
+	var err error
+	for _, e := range entries {
+		b = w.appendString(b, e.key)
+		if b, err = appendBinary(w, b, e.value, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// mapKeyOf returns the key of a map entry like json writes it
+func mapKeyOf(w binaryWriter, k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := marshalerOf(k, typeOfTextMarshaler); ok {
+		if m == nil {
+			return "", nil
+		}
+		text, err := m.(interface{ MarshalText() ([]byte, error) }).MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("%s: unsupported map key type %s", w.name(), k.Type())
+}
+
+// appendBinaryStruct writes a struct as a map of its json fields
+func appendBinaryStruct(w binaryWriter, b []byte, v reflect.Value, depth int) ([]byte, error) {
+	fields := jsonFieldsOf(v.Type())
+	values := make([]reflect.Value, len(fields))
+	n := 0
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values[i] = fv
+		n++
+	}
+	b = w.appendMapHead(b, n)
+	var err error
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		b = w.appendString(b, f.name)
+		if f.quoted {
+			b, err = appendQuoted(w, b, values[i])
+		} else {
+			b, err = appendBinary(w, b, values[i], depth+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendQuoted writes a field of the `string` option as the string of its json
+func appendQuoted(w binaryWriter, b []byte, v reflect.Value) ([]byte, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return w.appendNil(b), nil
+		}
+		v = v.Elem()
+	}
+	text, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return w.appendString(b, string(text)), nil
+}
+
+// fieldByIndex returns the field, ok is false if a pointer to an embedded struct on the way is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// jsonField is a field of a struct as json writes it
+type jsonField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool // the `string` option
+}
+
+var jsonFieldsCache sync.Map // map[reflect.Type][]jsonField
+
+// jsonFieldsOf returns the fields json writes of a struct type, in order, the fields of embedded structs
+// are promoted unless a shallower or tagged field has the same name, like encoding/json does
+func jsonFieldsOf(typ reflect.Type) []jsonField {
+	if fields, ok := jsonFieldsCache.Load(typ); ok {
+		return fields.([]jsonField)
+	}
+	all := appendJsonFields(nil, typ, nil, map[reflect.Type]bool{typ: true})
+	fields := make([]jsonField, 0, len(all))
+	for _, f := range all {
+		if dominantField(all, f) {
+			fields = append(fields, f)
+		}
+	}
+	jsonFieldsCache.Store(typ, fields)
+	return fields
+}
+
+func appendJsonFields(fields []jsonField, typ reflect.Type, index []int, visited map[reflect.Type]bool) []jsonField {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if sf.Anonymous {
+			if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+				continue
+			}
+			if name == "" && ft.Kind() == reflect.Struct {
+				if !visited[ft] {
+					visited[ft] = true
+					fields = appendJsonFields(fields, ft, fieldIndex, visited)
+					delete(visited, ft)
+				}
+				continue
+			}
+		} else if sf.PkgPath != "" {
+			continue
+		}
+		f := jsonField{name: name, index: fieldIndex, tagged: name != ""}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "string":
+				switch ft.Kind() {
+				case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+					reflect.Float32, reflect.Float64, reflect.String:
+					f.quoted = true
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// dominantField reports whether f is written among the fields of the same name: the shallowest one,
+// or the only tagged one of the shallowest, otherwise none of them
+func dominantField(all []jsonField, f jsonField) bool {
+	for _, g := range all {
+		if g.name != f.name || sameIndex(g.index, f.index) {
+			continue
+		}
+		switch {
+		case len(g.index) < len(f.index):
+			return false
+		case len(g.index) == len(f.index) && (g.tagged || !f.tagged):
+			return false
+		}
+	}
+	return true
+}
+
+func sameIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendBinaryTree writes a tree decoded from json, the value of a json.Marshaler
+func appendBinaryTree(w binaryWriter, b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return w.appendNil(b), nil
+	case bool:
+		return w.appendBool(b, v), nil
+	case jsonNumber:
+		i, u, f, kind := parseJsonNumber(v)
+		switch kind {
+		case numberInt:
+			return w.appendInt(b, i), nil
+		case numberUint:
+			return w.appendUint(b, u), nil
+		}
+		return w.appendFloat64(b, f), nil
+	case string:
+		return w.appendString(b, v), nil
+	case []interface{}:
+		b = w.appendArrayHead(b, len(v))
+		var err error
+		for _, e := range v {
+			if b, err = appendBinaryTree(w, b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = w.appendMapHead(b, len(v))
+		var err error
+		for _, k := range sortedKeys(v) {
+			b = w.appendString(b, k)
+			if b, err = appendBinaryTree(w, b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported type %T", w.name(), v)
+}
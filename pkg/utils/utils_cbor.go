@@ -0,0 +1,292 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// CBOR major types of RFC 8949
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const cborBreak = 0xff
+
+// CborMarshal encodes in as CBOR (RFC 8949), the fields are named by the `json` tags like ObjToByte.
+// It is encoded by reflection, []byte is written as a byte string and the numbers keep their type.
+func CborMarshal(in interface{}) ([]byte, error) {
+	return appendBinary(cborWriter{}, nil, reflect.ValueOf(in), 0)
+}
+
+// CborUnmarshal decodes CBOR into out, the fields are named by the `json` tags like ByteToObj.
+// A byte string is decoded as a base64 string, which is how json carries []byte, so that it is decoded
+// into a []byte, and tags are ignored.
+func CborUnmarshal(data []byte, out interface{}) error {
+	d := binaryDecoder{data: data}
+	tree, err := d.cbor(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("cbor: unexpected data after the top-level value")
+	}
+	return jsonTreeTo(tree, out)
+}
+
+// cborWriter is the binaryWriter of CBOR
+type cborWriter struct{}
+
+func (cborWriter) name() string { return "cbor" }
+
+func (cborWriter) appendNil(b []byte) []byte { return append(b, cborSimple|22) }
+
+func (cborWriter) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, cborSimple|21)
+	}
+	return append(b, cborSimple|20)
+}
+
+func (cborWriter) appendInt(b []byte, i int64) []byte {
+	if i < 0 {
+		return appendCborHead(b, cborNegInt, ^uint64(i))
+	}
+	return appendCborHead(b, cborUint, uint64(i))
+}
+
+func (cborWriter) appendUint(b []byte, u uint64) []byte { return appendCborHead(b, cborUint, u) }
+
+func (cborWriter) appendFloat32(b []byte, f float32) []byte {
+	return appendUint32(append(b, cborSimple|26), math.Float32bits(f))
+}
+
+func (cborWriter) appendFloat64(b []byte, f float64) []byte {
+	return appendUint64(append(b, cborSimple|27), math.Float64bits(f))
+}
+
+func (cborWriter) appendString(b []byte, s string) []byte {
+	return append(appendCborHead(b, cborText, uint64(len(s))), s...)
+}
+
+func (cborWriter) appendBytes(b []byte, p []byte) []byte {
+	return append(appendCborHead(b, cborBytes, uint64(len(p))), p...)
+}
+
+func (cborWriter) appendArrayHead(b []byte, n int) []byte {
+	return appendCborHead(b, cborArray, uint64(n))
+}
+
+func (cborWriter) appendMapHead(b []byte, n int) []byte { return appendCborHead(b, cborMap, uint64(n)) }
+
+// appendCborHead writes the initial byte of the major type with the argument n in the shortest form
+func appendCborHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return appendUint32(append(b, major|26), uint32(n))
+	}
+	return appendUint64(append(b, major|27), n)
+}
+
+// cborHead reads the argument of the initial byte c, indefinite is true for the additional information 31
+func (d *binaryDecoder) cborHead(c byte) (n uint64, indefinite bool, err error) {
+	switch info := c & 0x1f; {
+	case info < 24:
+		return uint64(info), false, nil
+	case info <= 27:
+		n, err = d.uint(1 << (info - 24))
+		return
+	case info == 31:
+		return 0, true, nil
+	}
+	return 0, false, fmt.Errorf("cbor: invalid additional information 0x%02x at offset %d", c, d.pos-1)
+}
+
+// cborBreakNext consumes the break code of an indefinite length item if it is next
+func (d *binaryDecoder) cborBreakNext() (bool, error) {
+	if d.pos >= len(d.data) {
+		return false, errBinaryTruncated
+	}
+	if d.data[d.pos] == cborBreak {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *binaryDecoder) cbor(depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("cbor: nested too deep")
+	}
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	major := c & 0xe0
+	if major == cborSimple {
+		return d.cborSimple(c)
+	}
+	n, indefinite, err := d.cborHead(c)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		raw, err := d.cborString(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return base64.StdEncoding.EncodeToString(raw), nil
+		}
+		return string(raw), nil
+	case cborArray:
+		var arr []interface{}
+		if !indefinite {
+			if n > uint64(len(d.data)-d.pos) {
+				return nil, errBinaryTruncated
+			}
+			arr = make([]interface{}, 0, n)
+		}
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite {
+				if done, err := d.cborBreakNext(); err != nil || done {
+					return arr, err
+				}
+			}
+			e, err := d.cbor(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, e)
+		}
+		return arr, nil
+	case cborMap:
+		m := make(map[string]interface{})
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite {
+				if done, err := d.cborBreakNext(); err != nil || done {
+					return m, err
+				}
+			}
+			k, err := d.cbor(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if m[mapKeyString(k)], err = d.cbor(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	// cborTag, the tagged item is decoded as it is
+	if indefinite {
+		return nil, errors.New("cbor: invalid indefinite length tag")
+	}
+	return d.cbor(depth + 1)
+}
+
+// cborString reads a byte or text string, an indefinite length one is the concatenation of its chunks
+func (d *binaryDecoder) cborString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errBinaryTruncated
+		}
+		return d.bytes(int(n))
+	}
+	var sb strings.Builder
+	for {
+		if done, err := d.cborBreakNext(); err != nil || done {
+			return []byte(sb.String()), err
+		}
+		c, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if c&0xe0 != major {
+			return nil, fmt.Errorf("cbor: invalid chunk 0x%02x at offset %d", c, d.pos-1)
+		}
+		chunkLen, chunkIndefinite, err := d.cborHead(c)
+		if err != nil || chunkIndefinite {
+			return nil, fmt.Errorf("cbor: invalid chunk 0x%02x at offset %d", c, d.pos-1)
+		}
+		chunk, err := d.cborString(major, chunkLen, false)
+		if err != nil {
+			return nil, err
+		}
+		sb.Write(chunk)
+	}
+}
+
+func (d *binaryDecoder) cborSimple(c byte) (interface{}, error) {
+	switch c & 0x1f {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null and undefined
+		return nil, nil
+	case 25:
+		u, err := d.uint(2)
+		return halfToFloat64(uint16(u)), err
+	case 26:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 27:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value 0x%02x at offset %d", c, d.pos-1)
+}
+
+// halfToFloat64 converts an IEEE 754 half-precision float
+func halfToFloat64(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCbor(t *testing.T) {
+	assertJsonRoundTrip(t, CborMarshal, CborUnmarshal)
+
+	// RFC 8949, appendix A
+	for in, want := range map[interface{}]string{
+		0:       "00",
+		23:      "17",
+		24:      "1818",
+		1000:    "1903e8",
+		1000000: "1a000f4240",
+		-1:      "20",
+		-1000:   "3903e7",
+		1.1:     "fb3ff199999999999a",
+		false:   "f4",
+		nil:     "f6",
+		"IETF":  "6449455446",
+		"ü":     "62c3bc",
+	} {
+		data, err := CborMarshal(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, hex.EncodeToString(data), in)
+	}
+	data, _ := CborMarshal([]byte{1, 2, 3, 4})
+	assert.Equal(t, "4401020304", hex.EncodeToString(data), "[]byte is a byte string")
+	data, _ = CborMarshal(float32(100000))
+	assert.Equal(t, "fa47c35000", hex.EncodeToString(data))
+	var u uint64
+	data, _ = CborMarshal(uint64(math.MaxUint64))
+	assert.Equal(t, "1bffffffffffffffff", hex.EncodeToString(data))
+	assert.NoError(t, CborUnmarshal(data, &u))
+	assert.Equal(t, uint64(math.MaxUint64), u)
+
+	for raw, want := range map[string]string{
+		"f93c00":     `1`,
+		"f97bff":     `65504`,
+		"fa47c35000": `100000`,
+		"c074323031332d30332d32315432303a30343a30305a": `"2013-03-21T20:04:00Z"`,
+		"4401020304":                 `"AQIDBA=="`,
+		"7f657374726561646d696e67ff": `"streaming"`,
+		"9f018202039f0405ffff":       `[1,[2,3],[4,5]]`,
+		"bf61610161629f0203ffff":     `{"a":1,"b":[2,3]}`,
+		"a201020304":                 `{"1":2,"3":4}`,
+	} {
+		b, _ := hex.DecodeString(raw)
+		var tree interface{}
+		if assert.NoError(t, CborUnmarshal(b, &tree), raw) {
+			got, _ := ObjToByte(tree)
+			assert.JSONEq(t, want, string(got), raw)
+		}
+	}
+
+	for _, bad := range []string{"", "1c", "62c3", "9a00010000", "7f6161", "f0", "0000"} {
+		b, _ := hex.DecodeString(bad)
+		assert.Error(t, CborUnmarshal(b, new(interface{})), bad)
+	}
+}
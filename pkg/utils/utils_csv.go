@@ -0,0 +1,296 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// CsvMarshal encodes a slice of structs, or a single struct, as csv with a header row.
+// The columns are named by the csv tag, then the json tag, then the field name, and a csv or json tag of "-" skips
+// the field. Nested structs are flattened into columns like address.city, and a time.Time is formatted as RFC 3339
+// unless the tag has a format option, like
+//
+//	CreatedAt time.Time `csv:"created_at,format=2006-01-02"`
+//
+// Slices and maps are written as json, and text cells starting with =, +, -, @, a tab or a carriage return
+// are prefixed with ' so that spreadsheets do not run them as formulas, see CsvEncoder.KeepFormulas.
+func CsvMarshal(data any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewCsvEncoder(&buf)
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() != reflect.Struct {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if err := enc.WriteHeader(rv.Type().Elem()); err != nil {
+			return nil, err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CsvEncodable reports whether CsvMarshal can encode the data, a struct or a slice of structs
+func CsvEncodable(data any) bool {
+	typ := reflect.TypeOf(data)
+	if typ == nil {
+		return false
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		for typ = typ.Elem(); typ.Kind() == reflect.Ptr; {
+			typ = typ.Elem()
+		}
+	}
+	return isCsvNested(typ)
+}
+
+// CsvEncoder writes structs as csv rows one by one, see CsvMarshal for how the columns are named and formatted
+type CsvEncoder struct {
+	// KeepFormulas writes the text cells starting like a formula as they are, instead of prefixing them with '
+	KeepFormulas bool
+
+	w       *csv.Writer
+	typ     reflect.Type
+	columns []csvColumn
+	record  []string
+}
+
+// NewCsvEncoder returns a CsvEncoder writing to w
+func NewCsvEncoder(w io.Writer) *CsvEncoder {
+	return &CsvEncoder{w: csv.NewWriter(w)}
+}
+
+// WriteHeader writes the header row of the struct type, once, Encode calls it for the type of the first row
+func (e *CsvEncoder) WriteHeader(typ reflect.Type) error {
+	if e.typ != nil {
+		return nil
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: can not encode %v as a row", typ)
+	}
+	e.typ, e.columns = typ, csvColumnsOf(typ)
+	header := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		header[i] = c.name
+	}
+	e.record = make([]string, len(e.columns))
+	return e.w.Write(header)
+}
+
+// Encode writes v, a struct or a pointer to one of the type of the header, as a row
+func (e *CsvEncoder) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return fmt.Errorf("csv: can not encode nil as a row")
+	}
+	if err := e.WriteHeader(rv.Type()); err != nil {
+		return err
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("csv: can not encode nil as a row")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Type() != e.typ {
+		return fmt.Errorf("csv: can not encode %v in the rows of %v", rv.Type(), e.typ)
+	}
+	for i, c := range e.columns {
+		cell, err := c.format(rv)
+		if err != nil {
+			return fmt.Errorf("csv: column %s: %w", c.name, err)
+		}
+		if c.text && !e.KeepFormulas && cell != "" && strings.IndexByte("=+-@\t\r", cell[0]) >= 0 {
+			cell = "'" + cell
+		}
+		e.record[i] = cell
+	}
+	return e.w.Write(e.record)
+}
+
+// Flush writes the buffered rows to the underlying writer
+func (e *CsvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvColumn is a leaf field of a struct, index is the path of fields from the row
+type csvColumn struct {
+	name       string
+	index      []int
+	timeFormat string
+	text       bool // not a number, bool or time, which may start like a formula
+}
+
+var csvColumnsCache sync.Map // map[reflect.Type][]csvColumn
+
+func csvColumnsOf(typ reflect.Type) []csvColumn {
+	if columns, ok := csvColumnsCache.Load(typ); ok {
+		return columns.([]csvColumn)
+	}
+	columns := appendCsvColumns(nil, typ, "", nil)
+	csvColumnsCache.Store(typ, columns)
+	return columns
+}
+
+func appendCsvColumns(columns []csvColumn, typ reflect.Type, prefix string, index []int) []csvColumn {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name, format, skip := csvNameOf(sf)
+		if skip || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if isCsvNested(ft) {
+			if sf.Anonymous && name == "" {
+				// promoted fields of an embedded struct
+				columns = appendCsvColumns(columns, ft, prefix, fieldIndex)
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			columns = appendCsvColumns(columns, ft, prefix+name+".", fieldIndex)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		columns = append(columns, csvColumn{name: prefix + name, index: fieldIndex, timeFormat: format, text: isCsvText(ft)})
+	}
+	return columns
+}
+
+// isCsvNested reports whether a field of the type is flattened into columns
+func isCsvNested(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ != typeOfTime && !reflect.PtrTo(typ).Implements(typeOfTextMarshaler)
+}
+
+// isCsvText reports whether a cell of the type may hold any text
+func isCsvText(typ reflect.Type) bool {
+	if typ == typeOfTime {
+		return false
+	}
+	if reflect.PtrTo(typ).Implements(typeOfTextMarshaler) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return false
+	}
+	return true
+}
+
+// csvNameOf returns the column name and time format of the csv tag, or the name of the json tag
+func csvNameOf(sf reflect.StructField) (name, format string, skip bool) {
+	tag, ok := sf.Tag.Lookup("csv")
+	if !ok {
+		tag = sf.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", "", true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if k, v, ok := strings.Cut(opt, "="); ok && k == "format" {
+			format = v
+		}
+	}
+	return parts[0], format, false
+}
+
+// format returns the cell of the column of the row, empty if a pointer on the way is nil
+func (c csvColumn) format(row reflect.Value) (string, error) {
+	v := row
+	for _, i := range c.index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == typeOfTime {
+		format := c.timeFormat
+		if format == "" {
+			format = time.RFC3339
+		}
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(format), nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(typeOfTextMarshaler) {
+		text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if v.Type().Implements(typeOfTextMarshaler) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		b, err := ObjToByte(v.Interface())
+		return string(b), err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type csvAddress struct {
+	City string `json:"city"`
+	Zip  string `csv:"zip_code"`
+}
+
+type csvAudit struct {
+	CreatedAt time.Time `csv:"created_at,format=2006-01-02"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type csvUser struct {
+	csvAudit
+	Id      int64       `json:"id"`
+	Name    string      `json:"name"`
+	Score   float64     `json:"score"`
+	Admin   bool        `json:"admin"`
+	Tags    []string    `json:"tags"`
+	Address *csvAddress `json:"address"`
+	IP      net.IP      `json:"ip"`
+	Secret  string      `json:"-"`
+	Note    string      `json:"note" csv:"-"`
+	hidden  string
+}
+
+func TestCsvMarshal(t *testing.T) {
+	day := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	users := []*csvUser{
+		{csvAudit: csvAudit{CreatedAt: day, UpdatedAt: day}, Id: 1, Name: `Jet "the" rocket`, Score: 9.5, Admin: true,
+			Tags: []string{"a", "b"}, Address: &csvAddress{City: "Paris", Zip: "75001"}, IP: net.IPv4(10, 0, 0, 1)},
+		{Id: 2, Name: "line\nbreak"},
+	}
+	b, err := CsvMarshal(users)
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at,updated_at,id,name,score,admin,tags,address.city,address.zip_code,ip\n"+
+		`2024-05-01,2024-05-01T08:30:00Z,1,"Jet ""the"" rocket",9.5,true,"[""a"",""b""]",Paris,75001,10.0.0.1`+"\n"+
+		",,2,\"line\nbreak\",0,false,null,,,\n", string(b))
+
+	// the header of an empty slice comes from the type
+	b, err = CsvMarshal([]csvAddress{})
+	assert.NoError(t, err)
+	assert.Equal(t, "city,zip_code\n", string(b))
+
+	b, err = CsvMarshal(csvAddress{City: "Lyon"})
+	assert.NoError(t, err)
+	assert.Equal(t, "city,zip_code\nLyon,\n", string(b))
+
+	_, err = CsvMarshal([]int{1})
+	assert.Error(t, err)
+}
+
+func TestCsvEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewCsvEncoder(&buf)
+	assert.NoError(t, enc.Encode(&csvAddress{City: "Paris"}))
+	assert.NoError(t, enc.Encode(csvAddress{City: "Lyon"}))
+	assert.Error(t, enc.Encode(csvAudit{}))
+	assert.NoError(t, enc.Flush())
+	assert.Equal(t, "city,zip_code\nParis,\nLyon,\n", buf.String())
+}
+
+type csvFormula struct {
+	Name  string  `csv:"name"`
+	Delta int     `csv:"delta"`
+	Score float64 `csv:"score"`
+}
+
+func TestCsvFormulas(t *testing.T) {
+	rows := []csvFormula{{Name: "=HYPERLINK(\"http://x\")", Delta: -5, Score: -1.5}, {Name: "@SUM(A1)"}, {Name: "-2+3"}, {Name: "jet"}}
+	b, err := CsvMarshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, "name,delta,score\n\"'=HYPERLINK(\"\"http://x\"\")\",-5,-1.5\n'@SUM(A1),0,0\n'-2+3,0,0\njet,0,0\n", string(b))
+
+	var buf bytes.Buffer
+	enc := NewCsvEncoder(&buf)
+	enc.KeepFormulas = true
+	assert.NoError(t, enc.Encode(csvFormula{Name: "=1+1"}))
+	assert.NoError(t, enc.Flush())
+	assert.Equal(t, "name,delta,score\n=1+1,0,0\n", buf.String())
+}
+
+func TestCsvEncodable(t *testing.T) {
+	assert.True(t, CsvEncodable(csvAddress{}))
+	assert.True(t, CsvEncodable(&csvAddress{}))
+	assert.True(t, CsvEncodable([]*csvAddress{}))
+	assert.False(t, CsvEncodable(map[string]any{}))
+	assert.False(t, CsvEncodable([]int{1}))
+	assert.False(t, CsvEncodable(time.Now()))
+	assert.False(t, CsvEncodable(nil))
+}
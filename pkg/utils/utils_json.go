@@ -110,3 +110,23 @@ func ObjToJsonStr(obj interface{}) (str string) {
 func Decode(reader io.Reader, obj interface{}) error {
 	return json.NewDecoder(reader).Decode(obj)
 }
+
+// jsonTreeOf returns the json representation of in as nil, bool, json.Number, string, []any and map[string]any,
+// the binary codecs encode this tree so that they name and omit fields exactly like json
+func jsonTreeOf(in interface{}) (tree interface{}, err error) {
+	var buf []byte
+	if buf, err = json.Marshal(in); err != nil {
+		return
+	}
+	err = ByteToObj(buf, &tree)
+	return
+}
+
+// jsonTreeTo decodes a tree decoded by the binary codecs into out like a json document
+func jsonTreeTo(tree interface{}, out interface{}) error {
+	buf, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return ByteToObj(buf, out)
+}
@@ -0,0 +1,350 @@
+// Copyright The Jet authors. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// MsgpackMarshal encodes in as MessagePack, the fields are named by the `json` tags like ObjToByte.
+// It is encoded by reflection, []byte is written as bin and the numbers keep their type.
+func MsgpackMarshal(in interface{}) ([]byte, error) {
+	return appendBinary(msgpackWriter{}, nil, reflect.ValueOf(in), 0)
+}
+
+// MsgpackUnmarshal decodes MessagePack into out, the fields are named by the `json` tags like ByteToObj.
+// bin is decoded as a base64 string, which is how json carries []byte, so that it is decoded into a []byte.
+func MsgpackUnmarshal(data []byte, out interface{}) error {
+	d := binaryDecoder{data: data}
+	tree, err := d.msgpack(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("msgpack: unexpected data after the top-level value")
+	}
+	return jsonTreeTo(tree, out)
+}
+
+// msgpackWriter is the binaryWriter of MessagePack
+type msgpackWriter struct{}
+
+func (msgpackWriter) name() string { return "msgpack" }
+
+func (msgpackWriter) appendNil(b []byte) []byte { return append(b, 0xc0) }
+
+func (msgpackWriter) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func (msgpackWriter) appendInt(b []byte, i int64) []byte { return appendMsgpackInt(b, i) }
+
+func (msgpackWriter) appendUint(b []byte, u uint64) []byte { return appendMsgpackUint(b, u) }
+
+func (msgpackWriter) appendFloat32(b []byte, f float32) []byte {
+	return appendUint32(append(b, 0xca), math.Float32bits(f))
+}
+
+func (msgpackWriter) appendFloat64(b []byte, f float64) []byte {
+	return appendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+func (msgpackWriter) appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func (msgpackWriter) appendBytes(b []byte, p []byte) []byte {
+	n := len(p)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, p...)
+}
+
+func (msgpackWriter) appendArrayHead(b []byte, n int) []byte {
+	return appendMsgpackLen(b, n, 0x90, 0xdc)
+}
+
+func (msgpackWriter) appendMapHead(b []byte, n int) []byte { return appendMsgpackLen(b, n, 0x80, 0xde) }
+
+// appendMsgpackLen writes the length of an array or map, fix is the fixarray or fixmap prefix
+// and wide is the 16 bits one, which is followed by the 32 bits one
+func appendMsgpackLen(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, wide), uint16(n))
+	}
+	return appendUint32(append(b, wide+1), uint32(n))
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgpackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(i))
+	}
+	return appendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgpackUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(u))
+	}
+	return appendUint64(append(b, 0xcf), u)
+}
+
+func (d *binaryDecoder) msgpack(depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("msgpack: nested too deep")
+	}
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.string(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.msgpackArray(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.msgpackMap(int(c&0x0f), depth)
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.bytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0:
+		u, err := d.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.string(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackMap(int(n), depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x at offset %d", c, d.pos-1)
+}
+
+func (d *binaryDecoder) msgpackArray(n int, depth int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errBinaryTruncated
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		var err error
+		if arr[i], err = d.msgpack(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+func (d *binaryDecoder) msgpackMap(n int, depth int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errBinaryTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.msgpack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if m[mapKeyString(k)], err = d.msgpack(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ----------------------------------------------------------------------
+
+// maxBinaryDepth limits the nesting of the binary codecs
+const maxBinaryDepth = 1000
+
+var errBinaryTruncated = errors.New("unexpected end of data")
+
+type jsonNumber = interface{ String() string }
+
+type numberKind int
+
+const (
+	numberInt numberKind = iota
+	numberUint
+	numberFloat
+)
+
+// parseJsonNumber tells a json number apart as int64, uint64 or float64
+func parseJsonNumber(n jsonNumber) (i int64, u uint64, f float64, kind numberKind) {
+	s := n.String()
+	var err error
+	if i, err = strconv.ParseInt(s, 10, 64); err == nil {
+		return i, 0, 0, numberInt
+	}
+	if u, err = strconv.ParseUint(s, 10, 64); err == nil {
+		return 0, u, 0, numberUint
+	}
+	f, _ = strconv.ParseFloat(s, 64)
+	return 0, 0, f, numberFloat
+}
+
+// binaryDecoder reads the binary codecs
+type binaryDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *binaryDecoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errBinaryTruncated
+	}
+	d.pos++
+	return d.data[d.pos-1], nil
+}
+
+func (d *binaryDecoder) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errBinaryTruncated
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos], nil
+}
+
+// uint reads a big endian unsigned integer of n bytes
+func (d *binaryDecoder) uint(n int) (uint64, error) {
+	b, err := d.bytes(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *binaryDecoder) string(n int) (interface{}, error) {
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func appendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapKeyString converts a decoded map key to the string key of json
+func mapKeyString(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
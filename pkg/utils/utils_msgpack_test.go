@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecAddress struct {
+	City string `json:"city"`
+}
+
+type codecUser struct {
+	Name     string            `json:"name"`
+	Age      int               `json:"age"`
+	Balance  int64             `json:"balance"`
+	Big      uint64            `json:"big"`
+	Score    float64           `json:"score"`
+	Active   bool              `json:"active"`
+	Avatar   []byte            `json:"avatar"`
+	Tags     []string          `json:"tags"`
+	Address  *codecAddress     `json:"address"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Created  time.Time         `json:"created"`
+	Nickname string            `json:"-"`
+	Note     *string           `json:"note"`
+}
+
+func newCodecUser() *codecUser {
+	return &codecUser{
+		Name:     "张三",
+		Age:      18,
+		Balance:  -1 << 40,
+		Big:      math.MaxUint64,
+		Score:    99.5,
+		Active:   true,
+		Avatar:   []byte{0, 1, 2, 255},
+		Tags:     []string{"a", strings.Repeat("b", 40), strings.Repeat("c", 300)},
+		Address:  &codecAddress{City: "杭州"},
+		Created:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Nickname: "skipped",
+	}
+}
+
+// assertJsonRoundTrip checks the codec decodes what it encodes into the same json representation
+func assertJsonRoundTrip(t *testing.T, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) {
+	in := newCodecUser()
+	data, err := marshal(in)
+	assert.NoError(t, err)
+	out := new(codecUser)
+	assert.NoError(t, unmarshal(data, out))
+	in.Nickname = ""
+	assert.Equal(t, in, out)
+
+	want, _ := ObjToByte(in)
+	var tree interface{}
+	assert.NoError(t, unmarshal(data, &tree))
+	got, _ := ObjToByte(tree)
+	assert.JSONEq(t, string(want), string(got))
+}
+
+func TestMsgpack(t *testing.T) {
+	assertJsonRoundTrip(t, MsgpackMarshal, MsgpackUnmarshal)
+
+	for in, want := range map[interface{}]string{
+		nil:      "c0",
+		true:     "c3",
+		1:        "01",
+		-1:       "ff",
+		200:      "ccc8",
+		-200:     "d1ff38",
+		-100:     "d09c",
+		70000:    "ce00011170",
+		1.5:      "cb3ff8000000000000",
+		"a":      "a161",
+		"hello!": "a668656c6c6f21",
+	} {
+		data, err := MsgpackMarshal(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, hex.EncodeToString(data), in)
+	}
+	data, _ := MsgpackMarshal(map[string]interface{}{"b": []int{1}, "a": 1})
+	assert.Equal(t, "82a16101a1629101", hex.EncodeToString(data), "keys are sorted")
+
+	// []byte is bin and float32 is float 32, not a base64 string and a float 64 like through json
+	data, _ = MsgpackMarshal([]byte{1, 2, 3})
+	assert.Equal(t, "c403010203", hex.EncodeToString(data))
+	data, _ = MsgpackMarshal(float32(1.5))
+	assert.Equal(t, "ca3fc00000", hex.EncodeToString(data))
+	data, _ = MsgpackMarshal(map[int]bool{2: true, 1: false})
+	assert.Equal(t, "82a131c2a132c3", hex.EncodeToString(data), "keys are written like json")
+	_, err := MsgpackMarshal(make(chan int))
+	assert.Error(t, err)
+
+	// formats that are never written are still read
+	var f float64
+	raw, _ := hex.DecodeString("ca3fc00000")
+	assert.NoError(t, MsgpackUnmarshal(raw, &f))
+	assert.Equal(t, 1.5, f)
+	var b []byte
+	raw, _ = hex.DecodeString("c403010203")
+	assert.NoError(t, MsgpackUnmarshal(raw, &b))
+	assert.Equal(t, []byte{1, 2, 3}, b)
+
+	for _, bad := range []string{"", "a5616263", "dc0005", "c1", "0101"} {
+		raw, _ = hex.DecodeString(bad)
+		assert.Error(t, MsgpackUnmarshal(raw, new(interface{})), bad)
+	}
+}
+
+type codecBase struct {
+	Id      int    `json:"id"`
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+type codecLabel string
+
+func (l codecLabel) MarshalText() ([]byte, error) {
+	return []byte("label:" + string(l)), nil
+}
+
+type codecItem struct {
+	codecBase
+	Name    string     `json:"name"`
+	Count   int64      `json:"count,string"`
+	Empty   string     `json:"empty,omitempty"`
+	Label   codecLabel `json:"label"`
+	Raw     codecRaw   `json:"raw"`
+	private int
+}
+
+type codecRaw []byte
+
+func (r codecRaw) MarshalJSON() ([]byte, error) {
+	return r, nil
+}
+
+func TestBinaryFields(t *testing.T) {
+	item := &codecItem{codecBase: codecBase{Id: 1, Name: "hidden"}, Name: "item", Count: 7, Label: "a",
+		Raw: codecRaw(`{"k":[1]}`), private: 1}
+	want := `{"id":1,"version":0,"name":"item","count":"7","label":"label:a","raw":{"k":[1]}}`
+	for _, codec := range []struct {
+		marshal   func(interface{}) ([]byte, error)
+		unmarshal func([]byte, interface{}) error
+	}{{MsgpackMarshal, MsgpackUnmarshal}, {CborMarshal, CborUnmarshal}} {
+		data, err := codec.marshal(item)
+		assert.NoError(t, err)
+		var tree interface{}
+		assert.NoError(t, codec.unmarshal(data, &tree))
+		got, _ := ObjToByte(tree)
+		assert.JSONEq(t, want, string(got))
+	}
+}
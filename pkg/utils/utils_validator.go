@@ -5,14 +5,207 @@
 package utils
 
 import (
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"reflect"
+	"strings"
+	"sync"
 )
 
-var validate = validator.New()
+var (
+	validate = validator.New()
+	// validateLock guards the custom type funcs registered while structs are validated
+	validateLock sync.RWMutex
+	// validatedTypes are the types whose ValidationValuer types are registered, see Struct
+	validatedTypes sync.Map
+)
+
+// ValidationValuer is implemented by wrapper types validated by the value they wrap, like param.Optional[T],
+// nil is validated as empty
+type ValidationValuer interface {
+	ValueInterface() any
+}
 
+var typeOfValidationValuer = reflect.TypeOf((*ValidationValuer)(nil)).Elem()
+
+// Struct validates s, the ValidationValuer types reachable from its type are registered on first use
 func Struct(s interface{}) error {
-	return validate.Struct(s)
+	if t := reflect.TypeOf(s); t != nil {
+		if _, ok := validatedTypes.Load(t); !ok {
+			registerValuerTypes(t)
+			validatedTypes.Store(t, true)
+		}
+	}
+	validateLock.RLock()
+	err := validate.Struct(s)
+	validateLock.RUnlock()
+	if validationErrs, ok := err.(validator.ValidationErrors); ok {
+		for i, fe := range validationErrs {
+			validationErrs[i] = jsonFieldError{FieldError: fe, path: jsonPathOf(reflect.TypeOf(s), fe.StructNamespace())}
+		}
+	}
+	return err
+}
+
+// jsonFieldError is a validator.FieldError of Struct knowing the json path of its field, see FieldErrors
+type jsonFieldError struct {
+	validator.FieldError
+	path string
+}
+
+// jsonPathOf maps the Go namespace of a field below the type t, like Req.Address.City, to its json path,
+// like address.city, the fields of an embedded struct are promoted like json does
+func jsonPathOf(t reflect.Type, namespace string) string {
+	_, rest, _ := strings.Cut(namespace, ".")
+	var path []string
+	for _, segment := range strings.Split(rest, ".") {
+		name, index, _ := strings.Cut(segment, "[")
+		if index != "" {
+			index = "[" + index
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		sf, ok := reflect.StructField{}, false
+		if t.Kind() == reflect.Struct {
+			sf, ok = t.FieldByName(name)
+		}
+		if !ok {
+			path = append(path, segment)
+			continue
+		}
+		t = sf.Type
+		jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if jsonName == "" && sf.Anonymous && index == "" {
+			continue
+		}
+		if jsonName == "" || jsonName == "-" {
+			jsonName = sf.Name
+		}
+		path = append(path, jsonName+index)
+		// the element type of every index, like Items[0]
+		for n := strings.Count(index, "["); n > 0; n-- {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			}
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// RegisterCustomTypeFunc registers fn to get the value to validate of the types,
+// see validator.Validate.RegisterCustomTypeFunc
+func RegisterCustomTypeFunc(fn validator.CustomTypeFunc, types ...interface{}) {
+	validateLock.Lock()
+	defer validateLock.Unlock()
+	validate.RegisterCustomTypeFunc(fn, types...)
+}
+
+// registerValuerTypes lets the validator check the wrapped value of every ValidationValuer reachable from t
+func registerValuerTypes(t reflect.Type) {
+	visited := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			walk(t.Elem())
+		case reflect.Struct:
+			if t.Implements(typeOfValidationValuer) {
+				RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+					return field.Interface().(ValidationValuer).ValueInterface()
+				}, reflect.New(t).Elem().Interface())
+			}
+			for i := 0; i < t.NumField(); i++ {
+				walk(t.Field(i).Type)
+			}
+		}
+	}
+	walk(t)
+}
+
+// FieldError is a struct field failing validation
+type FieldError struct {
+	Field   string `json:"field"` // the json path of the field below the validated struct, like address.city
+	Rule    string `json:"rule"`  // the failed validate tag, like required
+	Message string `json:"message"`
+}
+
+// FieldErrors returns the fields failing validation in the chain of err, nil if there is no validation error
+func FieldErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		var field string
+		if jf, ok := fe.(jsonFieldError); ok {
+			field = jf.path
+		} else if _, field, ok = strings.Cut(fe.Namespace(), "."); !ok {
+			field = fe.Namespace()
+		}
+		fields = append(fields, FieldError{Field: field, Rule: fe.Tag(), Message: fieldMessage(field, fe)})
+	}
+	return fields
+}
+
+// fieldMessage returns a human-readable message of the failed rule, like "name is required"
+func fieldMessage(field string, fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "url", "uri", "http_url":
+		return field + " must be a valid URL"
+	case "uuid", "uuid4":
+		return field + " must be a valid UUID"
+	case "numeric", "number":
+		return field + " must be a number"
+	case "oneof":
+		return field + " must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "len":
+		return sizeMessage(field, fe.Kind(), "", param)
+	case "min", "gte":
+		return sizeMessage(field, fe.Kind(), "at least ", param)
+	case "max", "lte":
+		return sizeMessage(field, fe.Kind(), "at most ", param)
+	case "gt":
+		return sizeMessage(field, fe.Kind(), "more than ", param)
+	case "lt":
+		return sizeMessage(field, fe.Kind(), "less than ", param)
+	case "eq":
+		return field + " must be " + param
+	case "ne":
+		return field + " must not be " + param
+	}
+	if param != "" {
+		return field + " must satisfy " + fe.Tag() + "=" + param
+	}
+	return field + " must satisfy " + fe.Tag()
+}
+
+// sizeMessage returns the message of a size rule, a length for strings and a number of items for collections
+func sizeMessage(field string, kind reflect.Kind, bound, param string) string {
+	switch kind {
+	case reflect.String:
+		return field + " must be " + bound + param + " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if param == "1" {
+			return field + " must have " + bound + "1 item"
+		}
+		return field + " must have " + bound + param + " items"
+	}
+	return field + " must be " + bound + param
 }
 
 // ProcessErr processes validation errors and returns an error message.
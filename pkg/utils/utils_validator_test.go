@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type validatedUser struct {
+	Name  string   `json:"name" validate:"min=2" reg_error_info:"too short"`
+	Role  string   `json:"role,omitempty" validate:"oneof=admin user"`
+	Tags  []string `json:"tags" validate:"max=1"`
+	Age   int      `json:"-" validate:"gte=18"`
+	Email string   `validate:"email"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	u := &validatedUser{Name: "a", Role: "root", Tags: []string{"a", "b"}, Age: 3, Email: "x"}
+	err := Struct(u)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Rule: "min", Message: "name must be at least 2 characters long"},
+		{Field: "role", Rule: "oneof", Message: "role must be one of admin, user"},
+		{Field: "tags", Rule: "max", Message: "tags must have at most 1 item"},
+		{Field: "Age", Rule: "gte", Message: "Age must be at least 18"},
+		{Field: "Email", Rule: "email", Message: "Email must be a valid email address"},
+	}, FieldErrors(err))
+	assert.Equal(t, "Name: too short", ProcessErr(u, err), "ProcessErr still names the Go field")
+	assert.Contains(t, err.Error(), "'validatedUser.Name'", "the validator errors still name the Go fields")
+	assert.Nil(t, FieldErrors(nil))
+}
+
+type validatedAudit struct {
+	CreatedBy string `json:"created_by" validate:"required"`
+}
+
+type validatedItem struct {
+	Sku string `json:"sku" validate:"required"`
+}
+
+type validatedOrder struct {
+	validatedAudit
+	Items []*validatedItem `json:"items" validate:"dive"`
+}
+
+func TestFieldErrorsJsonPath(t *testing.T) {
+	err := Struct(&validatedOrder{Items: []*validatedItem{{Sku: "a"}, {}}})
+	assert.Equal(t, []FieldError{
+		{Field: "created_by", Rule: "required", Message: "created_by is required"},
+		{Field: "items[1].sku", Rule: "required", Message: "items[1].sku is required"},
+	}, FieldErrors(err))
+}
+
+type validatedWrapper[T any] struct {
+	value T
+	set   bool
+}
+
+func (w validatedWrapper[T]) ValueInterface() any {
+	if !w.set {
+		return nil
+	}
+	return w.value
+}
+
+type validatedProfile struct {
+	Nick  validatedWrapper[string]  `json:"nick" validate:"omitempty,min=3"`
+	Admin *validatedWrapper[string] `json:"admin" validate:"omitempty,min=3"`
+}
+
+func TestStructValidationValuer(t *testing.T) {
+	assert.NoError(t, Struct(&validatedProfile{}), "an unset wrapper is empty")
+	err := Struct(&validatedProfile{Nick: validatedWrapper[string]{value: "ab", set: true}})
+	assert.Equal(t, []FieldError{{Field: "nick", Rule: "min", Message: "nick must be at least 3 characters long"}}, FieldErrors(err))
+	assert.NoError(t, Struct(validatedProfile{Nick: validatedWrapper[string]{value: "abc", set: true}}))
+	err = Struct(&validatedProfile{Admin: &validatedWrapper[string]{value: "x", set: true}})
+	assert.Equal(t, "admin", FieldErrors(err)[0].Field)
+}